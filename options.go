@@ -0,0 +1,255 @@
+package xlsxutil
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+type Option func(o *options)
+
+type options struct {
+	shading          string
+	decimalHeuristic bool
+	decimalMark      string
+	groupMark        string
+	precision        int
+	fuzzy            func(a, b string) bool
+	grouped          bool
+	json             bool
+	emptyAsZero      bool
+	scanFunc         func(s string, dst reflect.Value) error
+	aliases          map[string]string
+	inPlace          bool
+	moneyFormat      string
+	numberFormat     string
+	dateFormat       string
+	exportSheet      string
+	summaryBy        string
+	summarySheet     string
+	headerRow        int
+	headerPrefix     string
+	templateRow      int
+	uniqueKey        string
+	warn             func(msg string)
+	mergedFill       bool
+	formatted        bool
+	headerStyle      *xlsx.Style
+	maxBlankRows     int
+	skipped          func(row int)
+	skipWrite        func(v interface{}) bool
+	sheetFunc        func(name string, from, to int)
+	headerLimit      int
+	startColumn      int
+	skipMergedRows   bool
+	profile          *MappingProfile
+	delimiter        string
+}
+
+func newOptions(opts []Option) options {
+	o := options{precision: -1, headerLimit: 10, headerRow: -1, templateRow: -1, delimiter: ","}
+
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	return o
+}
+
+func WithRowShading(color string) Option {
+	return func(o *options) { o.shading = color }
+}
+
+func WithFloatPrecision(n int) Option {
+	return func(o *options) { o.precision = n }
+}
+
+func WithNumericMoney(format string) Option {
+	if format == "" {
+		format = "$#,##0.00"
+	}
+
+	return func(o *options) { o.moneyFormat = format }
+}
+
+func WithNumberFormat(format string) Option {
+	if format == "" {
+		format = "#,##0.00"
+	}
+
+	return func(o *options) { o.numberFormat = format }
+}
+
+func WithDateFormat(format string) Option {
+	return func(o *options) { o.dateFormat = format }
+}
+
+func WithExportTimestamp(sheet string) Option {
+	return func(o *options) { o.exportSheet = sheet }
+}
+
+func WithSummary(sheet, column string) Option {
+	return func(o *options) { o.summarySheet, o.summaryBy = sheet, column }
+}
+
+func WithUniqueKey(column string) Option {
+	return func(o *options) { o.uniqueKey = column }
+}
+
+func WithSkipFunc(fn func(v interface{}) bool) Option {
+	return func(o *options) { o.skipWrite = fn }
+}
+
+func WithSheetFunc(fn func(name string, from, to int)) Option {
+	return func(o *options) { o.sheetFunc = fn }
+}
+
+func WithTemplateRow(n int) Option {
+	return func(o *options) { o.templateRow = n }
+}
+
+func WithHeaderPrefix(prefix string) Option {
+	return func(o *options) { o.headerPrefix = prefix }
+}
+
+func WithHeaderRow(n int) Option {
+	return func(o *options) { o.headerRow = n }
+}
+
+func WithHeaderSearchLimit(n int) Option {
+	return func(o *options) { o.headerLimit = n }
+}
+
+func WithStartColumn(n int) Option {
+	return func(o *options) { o.startColumn = n }
+}
+
+func WithIgnoreMergedHeaderRows() Option {
+	return func(o *options) { o.skipMergedRows = true }
+}
+
+func WithProfile(p MappingProfile) Option {
+	return func(o *options) { o.profile = &p }
+}
+
+func WithDelimiter(d string) Option {
+	return func(o *options) { o.delimiter = d }
+}
+
+func WithEmptyAsZero() Option {
+	return func(o *options) { o.emptyAsZero = true }
+}
+
+func WithDecimalHeuristic() Option {
+	return func(o *options) { o.decimalHeuristic = true }
+}
+
+func WithDecimalSeparators(decimal, grouping string) Option {
+	return func(o *options) { o.decimalMark, o.groupMark = decimal, grouping }
+}
+
+func WithEuropeanNumbers() Option {
+	return WithDecimalSeparators(",", ".")
+}
+
+func (o options) number(s string) string {
+	if o.decimalMark != "" {
+		s = strings.Replace(groupSeparators.Replace(preprocessNumber(s)), " ", "", -1)
+		if o.groupMark != "" {
+			s = strings.Replace(s, o.groupMark, "", -1)
+		}
+
+		return strings.Replace(s, o.decimalMark, ".", 1)
+	}
+
+	s = cleanNumber(preprocessNumber(s))
+
+	if o.decimalHeuristic {
+		s = guessDecimal(s)
+	} else if o.grouped {
+		s = strings.Replace(s, ",", "", -1)
+		s = strings.Replace(s, " ", "", -1)
+	}
+
+	return s
+}
+
+func WithFuzzyFunc(fn func(a, b string) bool) Option {
+	return func(o *options) { o.fuzzy = fn }
+}
+
+// value gives a cell's text as it's stored, or as it's displayed if
+// WithFormattedValues was used.
+func (o options) value(c *xlsx.Cell) string {
+	if o.formatted {
+		if v, err := c.FormattedValue(); err == nil {
+			return v
+		}
+	}
+
+	return c.Value
+}
+
+// scanValue gives the text to scan from c into a value of type t. Formatted
+// text can't be read back reliably for dates and numbers, so those always
+// use the stored value.
+func (o options) scanValue(c *xlsx.Cell, t reflect.Type) string {
+	if isNumeric(t) || isTime(t) {
+		return c.Value
+	}
+
+	return o.value(c)
+}
+
+func (o options) match(a, b string) bool {
+	if o.fuzzy != nil {
+		return o.fuzzy(a, b)
+	}
+
+	return FuzzyFunc(a, b)
+}
+
+// header matches a header cell a against a column name b, ignoring the
+// prefix given with WithHeaderPrefix.
+func (o options) header(a, b string) bool {
+	if p := o.headerPrefix; p != "" {
+		if t := strings.TrimSpace(a); len(t) >= len(p) && strings.EqualFold(t[:len(p)], p) {
+			a = t[len(p):]
+		}
+	}
+
+	return o.match(a, b)
+}
+
+func WithInPlace() Option {
+	return func(o *options) { o.inPlace = true }
+}
+
+func WithWarnings(fn func(msg string)) Option {
+	return func(o *options) { o.warn = fn }
+}
+
+func WithFormattedValues() Option {
+	return func(o *options) { o.formatted = true }
+}
+
+func WithMergedFill() Option {
+	return func(o *options) { o.mergedFill = true }
+}
+
+func WithHeaderStyle(style *xlsx.Style) Option {
+	return func(o *options) { o.headerStyle = style }
+}
+
+func WithSkippedRows(fn func(row int)) Option {
+	return func(o *options) { o.skipped = fn }
+}
+
+func WithStopOnBlank() Option {
+	return WithMaxBlankRows(1)
+}
+
+func WithMaxBlankRows(n int) Option {
+	return func(o *options) { o.maxBlankRows = n }
+}
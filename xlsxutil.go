@@ -2,40 +2,133 @@ package xlsxutil
 
 import (
 	"encoding"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/pkg/errors"
 	"github.com/tealeg/xlsx"
 )
 
 func Sheet(doc *xlsx.File, name string) (*xlsx.Sheet, error) {
+	return sheet(doc, name, FuzzyFunc)
+}
+
+var ErrSheetNotFound = errors.New("sheet not found")
+
+type SheetNotFoundError struct {
+	Name      string
+	Available []string
+}
+
+func (e *SheetNotFoundError) Error() string {
+	return fmt.Sprintf("Sheet: couldn't find sheet %q; options were %#v", e.Name, e.Available)
+}
+
+func (e *SheetNotFoundError) Is(target error) bool {
+	return target == ErrSheetNotFound
+}
+
+func sheet(doc *xlsx.File, name string, match func(a, b string) bool) (*xlsx.Sheet, error) {
 	var found []string
 
 	for _, s := range doc.Sheets {
 		found = append(found, s.Name)
 
-		if Fuzzy(s.Name, name) {
+		if match(s.Name, name) {
 			return s, nil
 		}
 	}
 
-	return nil, errors.Errorf("Sheet: couldn't find sheet %q; options were %#v", name, found)
+	return nil, errors.WithStack(&SheetNotFoundError{Name: name, Available: found})
 }
 
+var FuzzyFunc func(a, b string) bool = Fuzzy
+
 func Fuzzy(a, b string) bool {
 	return strings.TrimSpace(strings.ToLower(a)) == strings.TrimSpace(strings.ToLower(b))
 }
 
+// FuzzyDistance gives a matching function for FuzzyFunc or WithFuzzyFunc that
+// also accepts names within n edits of each other, to cope with typos.
+func FuzzyDistance(n int) func(a, b string) bool {
+	return func(a, b string) bool {
+		a, b = strings.TrimSpace(strings.ToLower(a)), strings.TrimSpace(strings.ToLower(b))
+
+		return a != "" && b != "" && EditDistance(a, b) <= n
+	}
+}
+
+// EditDistance counts the single character insertions, deletions and
+// substitutions needed to turn a into b, like Levenshtein distance, but also
+// counts swapping two adjacent characters as a single edit.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// d[i][j] is the distance between the first i runes of a and the first j
+	// runes of b
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			v := d[i-1][j-1] + cost
+			if n := d[i-1][j] + 1; n < v {
+				v = n
+			}
+			if n := d[i][j-1] + 1; n < v {
+				v = n
+			}
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				if n := d[i-2][j-2] + 1; n < v {
+					v = n
+				}
+			}
+
+			d[i][j] = v
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
 func CopyStyles(to, from *xlsx.Cell) {
 	s1 := from.GetStyle()
 	s2 := *s1
 	to.SetStyle(&s2)
 }
 
+// CopyRowStyles copies the style and number format of each cell in from onto
+// the cell in the same column of to, adding cells to to as needed.
+func CopyRowStyles(to, from *xlsx.Row) {
+	for i, c := range from.Cells {
+		CopyStyles(Cell(to, i), c)
+
+		if c.NumFmt != "" && c.NumFmt != "general" {
+			to.Cells[i].NumFmt = c.NumFmt
+		}
+	}
+}
+
 func Cell(r *xlsx.Row, n int) *xlsx.Cell {
 	if len(r.Cells) == 0 {
 		r.AddCell()
@@ -48,29 +141,255 @@ func Cell(r *xlsx.Row, n int) *xlsx.Cell {
 	return r.Cells[n]
 }
 
+// NumericPreprocessor, if set, is applied to every cell before it's parsed as
+// a number, for organisation-specific cleanup.
+var NumericPreprocessor func(s string) string
+
+func preprocessNumber(s string) string {
+	if NumericPreprocessor == nil {
+		return s
+	}
+
+	return NumericPreprocessor(s)
+}
+
+// NormalizeCell, if set, is applied to every cell before it's scanned or
+// matched against a column name. By default it folds unicode spaces, like
+// no-break and thin spaces, to plain spaces.
+var NormalizeCell func(s string) string = foldSpaces
+
+func foldSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Zs, r) {
+			return ' '
+		}
+
+		return r
+	}, s)
+}
+
+func normalizeCell(s string) string {
+	if NormalizeCell == nil {
+		return s
+	}
+
+	return NormalizeCell(s)
+}
+
+// prepareCell trims c and applies NormalizeCell to it, unless it's going into
+// a number; cleanNumber needs to see unicode group separators as they are,
+// since once they're folded they look like any other space.
+func prepareCell(c string, t reflect.Type) string {
+	if isNumeric(t) {
+		return strings.TrimSpace(c)
+	}
+
+	return strings.TrimSpace(normalizeCell(c))
+}
+
+// isNumeric reports whether t, or what it points to, holds a number.
+func isNumeric(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return t == reflect.TypeOf(Decimal{})
+}
+
+// isTime reports whether t, or what it points to, is a time.Time.
+func isTime(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t == reflect.TypeOf(time.Time{})
+}
+
+var groupSeparators = strings.NewReplacer(
+	"\u00a0", "", // no-break space
+	"\u00b7", "", // middle dot
+	"\u2007", "", // figure space
+	"\u2009", "", // thin space
+	"\u202f", "", // narrow no-break space
+)
+
+// cleanNumber strips unicode digit group separators. Those are never used as
+// the decimal mark, so if one was present then any comma has to be the
+// decimal mark rather than another group separator.
+func cleanNumber(s string) string {
+	r := groupSeparators.Replace(s)
+	if r == s {
+		return s
+	}
+
+	if !strings.Contains(r, ".") && strings.Count(r, ",") == 1 {
+		r = strings.Replace(r, ",", ".", 1)
+	}
+
+	return r
+}
+
+// guessDecimal normalises s so that its decimal mark is a period, guessing
+// which separator is which: when both appear the last one is the decimal mark,
+// a repeated separator is grouping, and a lone separator followed by exactly
+// three digits (with a non-zero integer part) is grouping too.
+func guessDecimal(s string) string {
+	i := strings.LastIndexAny(s, ",.")
+	if i == -1 {
+		return s
+	}
+
+	dec, grp := ".", ","
+	if s[i] == ',' {
+		dec, grp = ",", "."
+	}
+
+	switch {
+	case strings.Contains(s, grp):
+		if strings.Count(s, dec) > 1 {
+			return s
+		}
+
+		return strings.Replace(strings.Replace(s, grp, "", -1), dec, ".", 1)
+	case strings.Count(s, dec) > 1:
+		return strings.Replace(s, dec, "", -1)
+	}
+
+	if whole := strings.TrimLeft(s[:i], "+-0"); len(s)-i-1 == 3 && whole != "" {
+		return strings.Replace(s, dec, "", 1)
+	}
+
+	return strings.Replace(s, dec, ".", 1)
+}
+
 type Money float64
 
 func MoneyPointer(v Money) *Money { return &v }
 
 func (m *Money) ScanString(s string) error {
-	s = strings.Replace(s, "$", "", -1)
-	s = strings.Replace(s, ",", "", -1)
+	v, err := ParseMoney(s, MoneyFormat{})
+	if err != nil {
+		return errors.Wrap(err, "Money.ScanString")
+	}
+
+	*m = v
+
+	return nil
+}
+
+type MoneyFormat struct {
+	Symbol   string
+	Decimal  string
+	Grouping string
+}
+
+func (f MoneyFormat) withDefaults() MoneyFormat {
+	if f.Symbol == "" {
+		f.Symbol = "$"
+	}
+	if f.Decimal == "" {
+		f.Decimal = "."
+	}
+	if f.Grouping == "" {
+		f.Grouping = ","
+		if f.Decimal == "," {
+			f.Grouping = "."
+		}
+	}
+
+	return f
+}
+
+func ParseMoney(s string, f MoneyFormat) (Money, error) {
+	f = f.withDefaults()
+
+	if f.Decimal == f.Grouping {
+		return 0, errors.Errorf("ParseMoney: decimal and grouping separators are both %q", f.Decimal)
+	}
+
+	s = preprocessNumber(s)
+
+	if f.Decimal == "." {
+		s = cleanNumber(s)
+	} else {
+		s = groupSeparators.Replace(s)
+	}
+
+	s = strings.Replace(s, f.Symbol, "", -1)
+	s = strings.Replace(s, f.Grouping, "", -1)
 	s = strings.Replace(s, " ", "", -1)
+	s = strings.Replace(s, f.Decimal, ".", -1)
 	s = strings.TrimSpace(s)
 
+	// accounting formats show negatives as "(1234.56)", "1234.56-" or
+	// "1234.56CR", and sometimes mark positives as "1234.56DR"
+	neg := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		neg, s = true, s[1:len(s)-1]
+	} else if strings.HasSuffix(s, "-") {
+		neg, s = true, s[:len(s)-1]
+	} else if u := strings.ToUpper(s); strings.HasSuffix(u, "CR") {
+		neg, s = true, s[:len(s)-2]
+	} else if strings.HasSuffix(u, "DR") {
+		s = s[:len(s)-2]
+	}
+
 	if s == "" {
-		*m = 0
-		return nil
+		return 0, nil
 	}
 
-	f, err := strconv.ParseFloat(s, 64)
+	n, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		return errors.Wrap(err, "Money.ScanString")
+		return 0, errors.Wrap(err, "ParseMoney")
 	}
 
-	*m = Money(f)
+	if neg {
+		n = -n
+	}
 
-	return nil
+	return Money(n), nil
+}
+
+func (m Money) Format(f MoneyFormat) string {
+	f = f.withDefaults()
+
+	s := fmt.Sprintf("%.02f", math.Abs(float64(m)))
+
+	whole, frac := s[:len(s)-3], s[len(s)-2:]
+
+	var b strings.Builder
+
+	if m < 0 {
+		b.WriteString("-")
+	}
+
+	b.WriteString(f.Symbol)
+
+	// grouping with the decimal mark would make the result ambiguous, so
+	// leave it out instead
+	for i := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 && f.Grouping != f.Decimal {
+			b.WriteString(f.Grouping)
+		}
+
+		b.WriteByte(whole[i])
+	}
+
+	b.WriteString(f.Decimal)
+	b.WriteString(frac)
+
+	return b.String()
 }
 
 func (m Money) String() string {
@@ -85,18 +404,59 @@ func (m Money) Round() string {
 	return fmt.Sprintf("%.02f", float64(m))
 }
 
+func (m Money) Cents() int64 {
+	f := float64(m)
+
+	neg := f < 0
+	if neg {
+		f = -f
+	}
+
+	// format with the shortest exact representation first so that values like
+	// 12.345 round half-up on their decimal form rather than on their binary
+	// approximation (which would be 12.3449999...)
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		whole, frac = s[:i], s[i+1:]
+	}
+
+	frac += "000"
+
+	n, _ := strconv.ParseInt(whole+frac[0:2], 10, 64)
+	if frac[2] >= '5' {
+		n++
+	}
+
+	if neg {
+		n = -n
+	}
+
+	return n
+}
+
 func (m *Money) Valid() bool {
 	return m != nil
 }
 
+var (
+	DaysPerYear  = 365
+	DaysPerMonth = 30
+)
+
 type Years int
 
 func YearsPointer(v Years) *Years { return &v }
 
 func (y *Years) ScanString(s string) error {
-	s = strings.ToLower(s)
-	s = strings.TrimSuffix(s, "years")
-	s = strings.TrimSuffix(s, "y")
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, unit := range []string{"years", "year", "yrs", "yr", "y"} {
+		if strings.HasSuffix(s, unit) {
+			s = strings.TrimSuffix(s, unit)
+			break
+		}
+	}
 	s = strings.Trim(s, "\t -")
 
 	n, err := strconv.ParseInt(s, 10, 64)
@@ -125,31 +485,102 @@ func (y Years) Months() Months {
 	return Months(y * 12)
 }
 
+// Days uses the DaysPerYear convention rather than calendar arithmetic.
+func (y Years) Days() int {
+	return int(y) * DaysPerYear
+}
+
 type Months int
 
 func MonthsPointer(v Months) *Months { return &v }
 
+// ScanString accepts a sequence of terms, each a whole number optionally
+// followed by a unit, and sums them into a month count. Terms may be separated
+// by whitespace, commas, hyphens, or "and". Units are case-insensitive:
+//
+//	years:  y, yr, yrs, year, years
+//	months: m, mo, mos, mth, mths, month, months
+//
+// A number without a unit is taken as months, but only on its own or as the
+// last term after a number of years. An empty string is zero, so
+// "18", "18m", "18 mo", "1y 6m", "1 year, 6 months", and "1 year and 6" are all
+// 18 months.
 func (m *Months) ScanString(s string) error {
-	s = strings.ToLower(s)
-	s = strings.TrimSuffix(s, "months")
-	s = strings.TrimSuffix(s, "m")
-	s = strings.Trim(s, "\t -")
+	var tokens []string
 
-	if s == "" {
-		*m = 0
-		return nil
+	for _, r := range strings.ToLower(s) {
+		var digit bool
+
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z':
+		case unicode.IsSpace(r) || r == ',' || r == '-':
+			tokens = append(tokens, "")
+			continue
+		default:
+			return errors.Errorf("Months.ScanString: unexpected %q in %q", r, s)
+		}
+
+		if len(tokens) == 0 || tokens[len(tokens)-1] == "" || isDigit(tokens[len(tokens)-1][0]) != digit {
+			tokens = append(tokens, "")
+		}
+
+		tokens[len(tokens)-1] += string(r)
 	}
 
-	n, err := strconv.ParseInt(s, 10, 64)
-	if err != nil {
-		return errors.Wrap(err, "Months.ScanString")
+	var total int64
+	var pending *int64
+
+	// a number can only go without a unit if it's the whole string, or the
+	// last term following a number of years
+	terms, afterYears := 0, false
+
+	for _, t := range tokens {
+		switch {
+		case t == "" || t == "and":
+			continue
+		case isDigit(t[0]):
+			if pending != nil {
+				return errors.Errorf("Months.ScanString: number %d in %q has no unit", *pending, s)
+			}
+
+			n, err := strconv.ParseInt(t, 10, 64)
+			if err != nil {
+				return errors.Wrap(err, "Months.ScanString")
+			}
+
+			pending = &n
+		case pending == nil:
+			return errors.Errorf("Months.ScanString: unit %q in %q isn't preceded by a number", t, s)
+		case contains([]string{"y", "yr", "yrs", "year", "years"}, t):
+			total += *pending * 12
+			pending, terms, afterYears = nil, terms+1, true
+		case contains([]string{"m", "mo", "mos", "mth", "mths", "month", "months"}, t):
+			total += *pending
+			pending, terms, afterYears = nil, terms+1, false
+		default:
+			return errors.Errorf("Months.ScanString: unknown unit %q in %q", t, s)
+		}
+	}
+
+	if pending != nil {
+		if terms > 0 && !afterYears {
+			return errors.Errorf("Months.ScanString: number %d in %q has no unit", *pending, s)
+		}
+
+		total += *pending
 	}
 
-	*m = Months(n)
+	*m = Months(total)
 
 	return nil
 }
 
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
 func (m Months) String() string {
 	return fmt.Sprintf("%d months", m)
 }
@@ -162,6 +593,105 @@ func (m Months) Enum() string {
 	return fmt.Sprintf("%d-months", m)
 }
 
+// Days uses the DaysPerMonth convention rather than calendar arithmetic.
+func (m Months) Days() int {
+	return int(m) * DaysPerMonth
+}
+
+func (m Months) Years() Years {
+	return Years(m / 12)
+}
+
+func (m Months) Split() (Years, Months) {
+	return Years(m / 12), m % 12
+}
+
+var weekdayNames = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+var monthNames = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+// scanName finds s in names, ignoring case and allowing it to be shortened to
+// as few as three letters ("Thu", "Thurs", "Sept"). It gives back the 1-based
+// position of the name, and also accepts that position written as a number.
+func scanName(s string, names []string) (int, bool) {
+	s = strings.ToLower(strings.TrimRight(strings.TrimSpace(s), "."))
+	if s == "" {
+		return 0, true
+	}
+
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n >= 1 && n <= len(names)
+	}
+
+	if len(s) < 3 {
+		return 0, false
+	}
+
+	for i, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), s) {
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// Weekday is a day of the week, numbered from 1 for Monday to 7 for Sunday.
+type Weekday int
+
+func WeekdayPointer(v Weekday) *Weekday { return &v }
+
+func (w *Weekday) ScanString(s string) error {
+	n, ok := scanName(s, weekdayNames)
+	if !ok {
+		return errors.Errorf("Weekday.ScanString: %q isn't a day of the week", s)
+	}
+
+	*w = Weekday(n)
+
+	return nil
+}
+
+func (w Weekday) String() string {
+	if w < 1 || int(w) > len(weekdayNames) {
+		return ""
+	}
+
+	return weekdayNames[w-1]
+}
+
+func (w Weekday) Code() string {
+	return fmt.Sprintf("%d", w)
+}
+
+// MonthName is a month of the year, numbered from 1 for January.
+type MonthName int
+
+func MonthNamePointer(v MonthName) *MonthName { return &v }
+
+func (m *MonthName) ScanString(s string) error {
+	n, ok := scanName(s, monthNames)
+	if !ok {
+		return errors.Errorf("MonthName.ScanString: %q isn't a month", s)
+	}
+
+	*m = MonthName(n)
+
+	return nil
+}
+
+func (m MonthName) String() string {
+	if m < 1 || int(m) > len(monthNames) {
+		return ""
+	}
+
+	return monthNames[m-1]
+}
+
+func (m MonthName) Code() string {
+	return fmt.Sprintf("%d", m)
+}
+
 type YesNo bool
 
 func YesNoPointer(v YesNo) *YesNo { return &v }
@@ -195,61 +725,497 @@ func (y YesNo) Code() string {
 	return "false"
 }
 
-type Range [2]int
+// bare (non-%) values with a magnitude below this are treated as fractions
+// rather than whole percentages, so "0.125" and "12.5" both mean 12.5%
+var PercentageFractionLimit = 1.0
 
-func (r *Range) ScanString(s string) error {
-	a := strings.Split(regexp.MustCompile("[^0-9]+").ReplaceAllString(s, " "), " ")
+type Percentage float64
 
-	if len(a) != 2 {
-		return errors.Errorf("Range.ScanString: expected two components; instead got %d (%v from %q)", len(a), a, s)
+func PercentagePointer(v Percentage) *Percentage { return &v }
+
+func (p *Percentage) ScanString(s string) error {
+	s = strings.TrimSpace(cleanNumber(preprocessNumber(s)))
+
+	if s == "" {
+		*p = 0
+		return nil
 	}
 
-	n1, err := strconv.ParseInt(a[0], 10, 64)
+	pct := strings.HasSuffix(s, "%")
+	s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+
+	f, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "Percentage.ScanString")
 	}
 
-	n2, err := strconv.ParseInt(a[1], 10, 64)
-	if err != nil {
-		return err
+	if pct || math.Abs(f) >= PercentageFractionLimit {
+		f /= 100
 	}
 
-	r[0] = int(n1)
-	r[1] = int(n2)
+	*p = Percentage(f)
 
 	return nil
 }
 
-func (r *Range) A() int { return r[0] }
-func (r *Range) B() int { return r[1] }
+func (p Percentage) String() string {
+	return fmt.Sprintf("%.02f%%", float64(p)*100)
+}
 
-func (r *Range) String() string {
-	return fmt.Sprintf("%d-%d", r[0], r[1])
+func (p Percentage) Code() string {
+	return fmt.Sprintf("%v", float64(p))
 }
 
-type Scanner interface {
-	ScanString(s string) error
+type Decimal struct {
+	Value int64
+	Scale int
 }
 
-func Find(r *xlsx.Row, names ...string) map[string]int {
-	res := make(map[string]int)
+func DecimalPointer(v Decimal) *Decimal { return &v }
 
-	for i, c := range r.Cells {
-		for _, name := range names {
-			if _, ok := res[name]; ok {
-				continue
-			}
+var decimalPattern = regexp.MustCompile(`^([+-]?)([0-9]*)(?:\.([0-9]*))?$`)
 
-			if Fuzzy(c.String(), name) {
-				res[name] = i
-			}
-		}
+func (d *Decimal) ScanString(s string) error {
+	s = cleanNumber(preprocessNumber(s))
+	s = strings.Replace(s, ",", "", -1)
+	s = strings.Replace(s, " ", "", -1)
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		*d = Decimal{}
+		return nil
+	}
+
+	m := decimalPattern.FindStringSubmatch(s)
+	if m == nil || m[2]+m[3] == "" {
+		return errors.Errorf("Decimal.ScanString: can't parse %q", s)
+	}
+
+	n, err := strconv.ParseInt(m[1]+m[2]+m[3], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "Decimal.ScanString")
+	}
+
+	*d = Decimal{Value: n, Scale: len(m[3])}
+
+	return nil
+}
+
+func (d Decimal) Rescale(scale int) Decimal {
+	v := d.Value
+
+	for s := d.Scale; s < scale; s++ {
+		v *= 10
+	}
+
+	for s := d.Scale; s > scale; s-- {
+		r := v % 10
+		v /= 10
+
+		if r >= 5 {
+			v++
+		} else if r <= -5 {
+			v--
+		}
+	}
+
+	return Decimal{Value: v, Scale: scale}
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.Value, 10)
+
+	sign := ""
+	if d.Value < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	if d.Scale <= 0 {
+		return sign + s
+	}
+
+	if len(s) <= d.Scale {
+		s = strings.Repeat("0", d.Scale-len(s)+1) + s
+	}
+
+	return sign + s[:len(s)-d.Scale] + "." + s[len(s)-d.Scale:]
+}
+
+func (d Decimal) Code() string {
+	return d.String()
+}
+
+type Range [2]int
+
+// the bounds given to the missing end of an open range like "5+" or "up to 10"
+const (
+	rangeMax = int(^uint(0) >> 1)
+	rangeMin = -rangeMax - 1
+)
+
+var rangeDigits = regexp.MustCompile("[0-9]+")
+
+func (r *Range) ScanString(s string) error {
+	a := strings.Split(regexp.MustCompile("[^0-9]+").ReplaceAllString(s, " "), " ")
+
+	if len(a) != 2 || a[0] == "" || a[1] == "" {
+		return r.scanOne(s)
+	}
+
+	n1, err := strconv.ParseInt(a[0], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	n2, err := strconv.ParseInt(a[1], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	r[0] = int(n1)
+	r[1] = int(n2)
+
+	return nil
+}
+
+// scanOne handles ranges with a single number, like "7", "5+" or "up to 10".
+func (r *Range) scanOne(s string) error {
+	d := rangeDigits.FindAllString(s, -1)
+	if len(d) != 1 {
+		return errors.Errorf("Range.ScanString: expected one or two components; instead got %d (%v from %q)", len(d), d, s)
+	}
+
+	n, err := strconv.ParseInt(d[0], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "Range.ScanString")
+	}
+
+	t := strings.TrimSpace(strings.ToLower(s))
+	rest := strings.TrimSpace(strings.Replace(t, d[0], "", 1))
+
+	switch rest {
+	case "":
+		r[0], r[1] = int(n), int(n)
+	case "+", "and up", "or more":
+		r[0], r[1] = int(n), rangeMax
+	case "up to", "or less":
+		r[0], r[1] = rangeMin, int(n)
+	default:
+		return errors.Errorf("Range.ScanString: couldn't understand %q", s)
+	}
+
+	return nil
+}
+
+func (r *Range) A() int { return r[0] }
+func (r *Range) B() int { return r[1] }
+
+// Open reports whether either end of the range is unbounded.
+func (r *Range) Open() bool { return r[0] == rangeMin || r[1] == rangeMax }
+
+func (r *Range) Contains(n int) bool { return r[0] <= n && n <= r[1] }
+
+func (r *Range) String() string {
+	switch {
+	case r[1] == rangeMax:
+		return fmt.Sprintf("%d+", r[0])
+	case r[0] == rangeMin:
+		return fmt.Sprintf("up to %d", r[1])
+	}
+
+	return fmt.Sprintf("%d-%d", r[0], r[1])
+}
+
+type Coord struct {
+	Lat float64
+	Lng float64
+}
+
+func CoordPointer(v Coord) *Coord { return &v }
+
+func (c *Coord) ScanString(s string) error {
+	a := strings.Split(s, ",")
+
+	if len(a) != 2 {
+		return errors.Errorf("Coord.ScanString: expected two components; instead got %d (%v from %q)", len(a), a, s)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(a[0]), 64)
+	if err != nil {
+		return errors.Wrap(err, "Coord.ScanString")
+	}
+
+	lng, err := strconv.ParseFloat(strings.TrimSpace(a[1]), 64)
+	if err != nil {
+		return errors.Wrap(err, "Coord.ScanString")
+	}
+
+	c.Lat = lat
+	c.Lng = lng
+
+	return nil
+}
+
+func (c Coord) String() string {
+	return fmt.Sprintf("%v, %v", c.Lat, c.Lng)
+}
+
+// Phone holds a phone number as its digits, with a leading "+" if it was
+// written with a country code.
+type Phone string
+
+func PhonePointer(v Phone) *Phone { return &v }
+
+// ScanString drops spaces, dots, dashes, slashes and parentheses, and errors
+// on anything else that isn't a digit or a leading "+". A number has to have
+// between 7 and 15 digits, the latter being the E.164 limit.
+func (p *Phone) ScanString(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		*p = ""
+		return nil
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case strings.ContainsRune(" .-/()", r):
+		default:
+			return errors.Errorf("Phone.ScanString: unexpected %q in %q", r, s)
+		}
+	}
+
+	v := b.String()
+
+	if n := len(strings.TrimPrefix(v, "+")); n < 7 || n > 15 {
+		return errors.Errorf("Phone.ScanString: expected 7 to 15 digits; instead got %d in %q", n, s)
+	}
+
+	*p = Phone(v)
+
+	return nil
+}
+
+// E164 gives the number with a country code, using cc if it didn't have one.
+// A leading 0 trunk prefix is dropped in that case.
+func (p Phone) E164(cc string) string {
+	if strings.HasPrefix(string(p), "+") || p == "" {
+		return string(p)
+	}
+
+	return "+" + strings.TrimPrefix(cc, "+") + strings.TrimPrefix(string(p), "0")
+}
+
+// String formats North American numbers like "(555) 123-4567" or
+// "+1 (555) 123-4567", and gives anything else back as it's stored.
+func (p Phone) String() string {
+	v := string(p)
+
+	switch {
+	case len(v) == 10 && v[0] >= '2' && v[0] <= '9':
+		return fmt.Sprintf("(%s) %s-%s", v[0:3], v[3:6], v[6:])
+	case len(v) == 12 && strings.HasPrefix(v, "+1"):
+		return fmt.Sprintf("+1 (%s) %s-%s", v[2:5], v[5:8], v[8:])
+	}
+
+	return v
+}
+
+func (p Phone) Code() string {
+	return string(p)
+}
+
+type Scanner interface {
+	ScanString(s string) error
+}
+
+// OptString is a string that remembers whether it was read at all, so that an
+// empty cell can be told apart from a column that's missing from the sheet.
+// Present is set whenever the column exists, and Valid only if the cell had
+// something in it.
+type OptString struct {
+	Value   string
+	Present bool
+	Valid   bool
+}
+
+func (o *OptString) ScanString(s string) error {
+	o.Value, o.Present, o.Valid = s, true, s != ""
+
+	return nil
+}
+
+func (o OptString) String() string {
+	if !o.Valid {
+		return ""
+	}
+
+	return o.Value
+}
+
+// EnumSet is a Scanner accepting only a fixed set of values, matched ignoring
+// case and stored in their canonical form. An EnumSet or *EnumSet field takes
+// its set from the field's values tag option:
+//
+//	Status xlsxutil.EnumSet `xlsx:"Status,values:Active|Inactive|Pending"`
+type EnumSet struct {
+	Value   string
+	allowed []string
+}
+
+func NewEnum(allowed ...string) *EnumSet {
+	return &EnumSet{allowed: allowed}
+}
+
+func (e *EnumSet) Parse(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	if len(e.allowed) == 0 {
+		return "", errors.Errorf("EnumSet.Parse: no allowed values; use NewEnum or a values tag option")
+	}
+
+	for _, a := range e.allowed {
+		if normalizeEnum(a) == normalizeEnum(s) {
+			return a, nil
+		}
+	}
+
+	return "", errors.Errorf("EnumSet.Parse: %q isn't one of %s", s, strings.Join(e.allowed, ", "))
+}
+
+func (e *EnumSet) ScanString(s string) error {
+	v, err := e.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	e.Value = v
+
+	return nil
+}
+
+func (e EnumSet) String() string {
+	return e.Value
+}
+
+// bindEnum gives an EnumSet or *EnumSet in v the allowed values from a values
+// tag option, if it doesn't already have any.
+func bindEnum(v reflect.Value, values []string) {
+	if !v.CanAddr() {
+		return
+	}
+
+	var e *EnumSet
+
+	switch p := v.Addr().Interface().(type) {
+	case *EnumSet:
+		e = p
+	case **EnumSet:
+		if *p == nil {
+			*p = &EnumSet{}
+		}
+		e = *p
+	default:
+		return
+	}
+
+	if len(e.allowed) == 0 {
+		for _, s := range values {
+			e.allowed = append(e.allowed, strings.TrimSpace(s))
+		}
+	}
+}
+
+func Find(r *xlsx.Row, names ...string) map[string]int {
+	return find(r, FuzzyFunc, names...)
+}
+
+func find(r *xlsx.Row, match func(a, b string) bool, names ...string) map[string]int {
+	return findBelow(nil, r, match, names...)
+}
+
+// findBelow locates each name in r. A name may list several candidates
+// separated by "|", which are tried in order; the result is keyed by the first
+// one. A candidate like "Gross>Amount" only matches an "Amount" column that
+// sits under a "Gross" group label in the row above.
+func findBelow(above, r *xlsx.Row, match func(a, b string) bool, names ...string) map[string]int {
+	res := make(map[string]int)
+
+	for _, name := range names {
+		alts := strings.Split(name, "|")
+
+	search:
+		for _, alt := range alts {
+			parent, child := splitParent(alt)
+
+			// when match is approximate, like FuzzyDistance, a header it
+			// accepts that's also an exact match wins over one further along
+			for _, exact := range []bool{true, false} {
+				for i, c := range r.Cells {
+					v := normalizeCell(c.String())
+					if !match(v, child) || (exact && !Fuzzy(v, child)) {
+						continue
+					}
+
+					if parent != "" && (above == nil || !match(normalizeCell(groupLabel(above, i)), parent)) {
+						continue
+					}
+
+					res[alts[0]] = i
+					break search
+				}
+			}
+		}
 	}
 
 	return res
 }
 
+func splitParent(name string) (string, string) {
+	if i := strings.Index(name, ">"); i != -1 {
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
+
+// groupLabel finds the label spanning column i of a group header row, which
+// is the nearest non-empty cell at or to the left of it; merged cells only
+// keep their value in the leftmost cell.
+func groupLabel(r *xlsx.Row, i int) string {
+	if i >= len(r.Cells) {
+		i = len(r.Cells) - 1
+	}
+
+	for ; i >= 0; i-- {
+		if v := r.Cells[i].String(); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
 func FindHeader(s *xlsx.Sheet, limit int, names ...string) (int, map[string]int) {
+	return findHeader(s, limit, newOptions(nil), names...)
+}
+
+func FindHeaderFull(s *xlsx.Sheet, names ...string) (int, map[string]int) {
+	return findHeader(s, len(s.Rows), newOptions(nil), names...)
+}
+
+func findHeader(s *xlsx.Sheet, limit int, o options, names ...string) (int, map[string]int) {
 	if limit >= len(s.Rows) {
 		limit = len(s.Rows) - 1
 	}
@@ -258,7 +1224,16 @@ func FindHeader(s *xlsx.Sheet, limit int, names ...string) (int, map[string]int)
 	var bestCols map[string]int
 
 	for i := 0; i <= limit; i++ {
-		a := Find(s.Rows[i], names...)
+		if o.skipMergedRows && hasHMerge(s.Rows[i]) {
+			continue
+		}
+
+		var above *xlsx.Row
+		if i > 0 {
+			above = s.Rows[i-1]
+		}
+
+		a := findBelow(above, s.Rows[i], o.header, names...)
 
 		if len(a) == len(names) {
 			return i, a
@@ -273,333 +1248,2676 @@ func FindHeader(s *xlsx.Sheet, limit int, names ...string) (int, map[string]int)
 	return bestRow, bestCols
 }
 
-func Scan(r *xlsx.Row, out ...interface{}) error {
-	for i, e := range out {
-		c := ""
+func hasHMerge(r *xlsx.Row) bool {
+	for _, c := range r.Cells {
+		if c.HMerge > 0 {
+			return true
+		}
+	}
 
-		if len(r.Cells) > i {
-			c = strings.TrimSpace(r.Cells[i].Value)
+	return false
+}
+
+func RenameHeader(s *xlsx.Sheet, headerRow int, from, to string) error {
+	if headerRow < 0 || headerRow >= len(s.Rows) {
+		return errors.Errorf("RenameHeader: header row %d is out of range; sheet has %d rows", headerRow, len(s.Rows))
+	}
+
+	for _, c := range s.Rows[headerRow].Cells {
+		if FuzzyFunc(c.String(), from) {
+			c.SetString(to)
+			return nil
 		}
+	}
 
-		switch e := e.(type) {
-		case nil:
-			// nothing
-		case *string:
-			*e = c
-		case *int:
-			n, err := strconv.ParseInt(c, 10, 64)
-			if err != nil {
-				return errors.Wrapf(err, "Scan(%T)", e)
-			}
-			*e = int(n)
-		case **int:
-			if c == "" {
-				*e = nil
-			} else {
-				n, err := strconv.ParseInt(c, 10, 64)
-				if err != nil {
-					return errors.Wrapf(err, "Scan(%T)", e)
-				}
-				v := int(n)
-				*e = &v
-			}
-		case *float64:
-			n, err := strconv.ParseFloat(c, 64)
-			if err != nil {
-				return errors.Wrapf(err, "Scan(%T)", e)
-			}
-			*e = n
-		case **float64:
-			if c == "" {
-				*e = nil
-			} else {
-				n, err := strconv.ParseFloat(c, 64)
-				if err != nil {
-					return errors.Wrapf(err, "Scan(%T)", e)
-				}
-				*e = &n
-			}
-		default:
-			p := reflect.ValueOf(e)
+	return errors.Errorf("RenameHeader: couldn't find column %q in row %d", from, headerRow)
+}
 
-			if p.Type().Kind() != reflect.Ptr {
-				return fmt.Errorf("can't scan into %T; must be a pointer", e)
-			}
+func MergeDuplicateColumns(s *xlsx.Sheet, headerRow int) error {
+	if headerRow < 0 || headerRow >= len(s.Rows) {
+		return errors.Errorf("MergeDuplicateColumns: header row %d is out of range; sheet has %d rows", headerRow, len(s.Rows))
+	}
 
-			if t := p.Type().Elem(); t.Kind() == reflect.Ptr && c == "" {
-				p.Elem().Set(reflect.Zero(t))
-				continue
+	header := s.Rows[headerRow].Cells
+
+	groups := make(map[int][]int)
+	dropped := make(map[int]bool)
+	var drop []int
+
+	for i := range header {
+		if header[i].String() == "" || dropped[i] {
+			continue
+		}
+
+		for j := i + 1; j < len(header); j++ {
+			if !dropped[j] && FuzzyFunc(header[i].String(), header[j].String()) {
+				groups[i] = append(groups[i], j)
+				dropped[j] = true
+				drop = append(drop, j)
 			}
+		}
+	}
+
+	if len(drop) == 0 {
+		return nil
+	}
+
+	for _, r := range s.Rows[headerRow+1:] {
+		for i, js := range groups {
+			mergeCells(r, i, js)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(drop)))
+
+	// rows above the header are shifted too, so titles stay over the
+	// columns they were over
+	for _, r := range s.Rows {
+		for _, j := range drop {
+			if j < len(r.Cells) {
+				r.Cells = append(r.Cells[:j], r.Cells[j+1:]...)
+			}
+		}
+	}
+
+	for _, j := range drop {
+		dropCol(s, j)
+	}
+
+	if s.MaxCol -= len(drop); s.MaxCol < 0 {
+		s.MaxCol = 0
+	}
+
+	return nil
+}
+
+// dropCol takes column j out of the column ranges of s, shifting the ranges
+// after it left. Unlike j, the ranges' Min and Max are 1-based.
+func dropCol(s *xlsx.Sheet, j int) {
+	n := j + 1
+	cols := s.Cols[:0]
+
+	for _, c := range s.Cols {
+		switch {
+		case c.Max < n:
+		case c.Min > n:
+			c.Min, c.Max = c.Min-1, c.Max-1
+		case c.Min == c.Max:
+			continue
+		default:
+			c.Max--
+		}
+
+		cols = append(cols, c)
+	}
+
+	s.Cols = cols
+}
+
+func mergeCells(r *xlsx.Row, i int, js []int) {
+	var values []string
+
+	for _, j := range append([]int{i}, js...) {
+		if j < len(r.Cells) {
+			if v := strings.TrimSpace(r.Cells[j].Value); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return
+	}
+
+	var sum float64
+	numeric := true
+
+	for _, v := range values {
+		f, err := strconv.ParseFloat(options{grouped: true}.number(v), 64)
+		if err != nil {
+			numeric = false
+			break
+		}
+
+		sum += f
+	}
+
+	if numeric {
+		Cell(r, i).SetFloat(sum)
+	} else {
+		Cell(r, i).SetString(strings.Join(values, ", "))
+	}
+}
+
+// SortSheet sorts the rows below a sheet's header by the given columns in
+// order, comparing numbers numerically. Blank rows at the end stay there.
+func SortSheet(doc *xlsx.File, name string, by ...string) error {
+	if len(by) == 0 {
+		return errors.Errorf("SortSheet: expected at least one column to sort by")
+	}
+
+	o := newOptions(nil)
+
+	s, err := sheet(doc, name, o.match)
+	if err != nil {
+		return errors.Wrap(err, "SortSheet")
+	}
+
+	header, cols := findHeader(s, o.headerLimit, o, by...)
+	if len(cols) != len(by) {
+		var missing []string
+		for _, n := range by {
+			if _, ok := cols[primaryName(n)]; !ok {
+				missing = append(missing, n)
+			}
+		}
+
+		return errors.Errorf("SortSheet: couldn't find some columns: %s", strings.Join(missing, ", "))
+	}
+
+	end := len(s.Rows)
+	for end > header+1 && isBlank(s.Rows[end-1]) {
+		end--
+	}
+
+	rows := s.Rows[header+1 : end]
+
+	value := func(r *xlsx.Row, i int) string {
+		if i < len(r.Cells) {
+			return strings.TrimSpace(r.Cells[i].String())
+		}
+
+		return ""
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		for _, n := range by {
+			i := cols[primaryName(n)]
+
+			if c := compareValues(value(rows[a], i), value(rows[b], i)); c != 0 {
+				return c < 0
+			}
+		}
+
+		return false
+	})
+
+	return nil
+}
+
+// compareValues orders numbers before text, numbers by value and text
+// alphabetically.
+func compareValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+
+	switch {
+	case errA == nil && errB == nil:
+		if fa < fb {
+			return -1
+		} else if fa > fb {
+			return 1
+		}
+
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func Scan(r *xlsx.Row, out ...interface{}) error {
+	for i, e := range out {
+		c := ""
+
+		if len(r.Cells) > i {
+			c = prepareCell(r.Cells[i].Value, reflect.TypeOf(e))
+		}
+
+		if err := scan(c, e, newOptions(nil)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseTime(s string) (time.Time, error) {
+	// some systems store dates as integers like 20210314, which would be
+	// tens of thousands of years away as an Excel serial date
+	if len(s) == 8 && strings.Trim(s, "0123456789") == "" {
+		if t, err := time.Parse("20060102", s); err == nil {
+			return t, nil
+		}
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return xlsx.TimeFromExcelTime(f, false), nil
+	}
+
+	for _, l := range timeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("parseTime: couldn't parse %q as a date", s)
+}
+
+// isNumber reports whether e points directly to a number, as opposed to a
+// pointer to one.
+func isNumber(e interface{}) bool {
+	p := reflect.ValueOf(e)
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		return false
+	}
+
+	switch p.Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// scanSlice splits c on o.delimiter and scans each trimmed part into a new
+// element of v. An empty cell gives a nil slice.
+func scanSlice(c string, v reflect.Value, o options) error {
+	v.Set(reflect.Zero(v.Type()))
+
+	if strings.TrimSpace(c) == "" {
+		return nil
+	}
+
+	for _, part := range strings.Split(c, o.delimiter) {
+		e := reflect.New(v.Type().Elem())
+		if err := scan(strings.TrimSpace(part), e.Interface(), o); err != nil {
+			return errors.Wrapf(err, "Scan(%s): element %d", v.Type(), v.Len())
+		}
+
+		v.Set(reflect.Append(v, e.Elem()))
+	}
+
+	return nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "1", "yes", "y":
+		return true, nil
+	case "false", "0", "no", "n", "":
+		return false, nil
+	}
+
+	return false, errors.Errorf("parseBool: can't parse %q as a bool", s)
+}
+
+func scan(c string, e interface{}, o options) error {
+	if c == "" && o.emptyAsZero && isNumber(e) {
+		p := reflect.ValueOf(e).Elem()
+		p.Set(reflect.Zero(p.Type()))
+		return nil
+	}
+
+	switch e := e.(type) {
+	case nil:
+		// nothing
+	case *string:
+		*e = c
+	case *int:
+		n, err := strconv.ParseInt(o.number(c), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "Scan(%T)", e)
+		}
+		*e = int(n)
+	case **int:
+		if c == "" {
+			*e = nil
+		} else {
+			n, err := strconv.ParseInt(o.number(c), 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			v := int(n)
+			*e = &v
+		}
+	case *float64:
+		n, err := strconv.ParseFloat(o.number(c), 64)
+		if err != nil {
+			return errors.Wrapf(err, "Scan(%T)", e)
+		}
+		*e = n
+	case **float64:
+		if c == "" {
+			*e = nil
+		} else {
+			n, err := strconv.ParseFloat(o.number(c), 64)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			*e = &n
+		}
+	case *bool:
+		b, err := parseBool(c)
+		if err != nil {
+			return errors.Wrapf(err, "Scan(%T)", e)
+		}
+		*e = b
+	case **bool:
+		if c == "" {
+			*e = nil
+		} else {
+			b, err := parseBool(c)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			*e = &b
+		}
+	case *time.Time:
+		if c == "" {
+			*e = time.Time{}
+		} else {
+			t, err := parseTime(c)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			*e = t
+		}
+	case **time.Time:
+		if c == "" {
+			*e = nil
+		} else {
+			t, err := parseTime(c)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			*e = &t
+		}
+	case *net.IP:
+		if c == "" {
+			*e = nil
+		} else {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return errors.Errorf("Scan(%T): couldn't parse %q as an IP address", e, c)
+			}
+			*e = ip
+		}
+	case *net.IPNet:
+		if c == "" {
+			*e = net.IPNet{}
+		} else {
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			*e = *n
+		}
+	case **net.IPNet:
+		if c == "" {
+			*e = nil
+		} else {
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			*e = n
+		}
+	default:
+		p := reflect.ValueOf(e)
+
+		if p.Type().Kind() != reflect.Ptr {
+			return fmt.Errorf("can't scan into %T; must be a pointer", e)
+		}
+
+		if o.json {
+			if c == "" {
+				p.Elem().Set(reflect.Zero(p.Type().Elem()))
+				return nil
+			}
+
+			if err := json.Unmarshal([]byte(c), e); err != nil {
+				return errors.Wrapf(err, "Scan(%T) (json)", e)
+			}
+
+			return nil
+		}
+
+		if t := p.Type().Elem(); t.Kind() == reflect.Ptr && c == "" {
+			p.Elem().Set(reflect.Zero(t))
+			return nil
+		}
+
+		if p.Type().Elem().Kind() == reflect.Ptr {
+			if p.Elem().IsNil() {
+				p.Elem().Set(reflect.New(p.Type().Elem().Elem()))
+			}
+
+			p = p.Elem()
+		}
+
+		v := p.Interface()
+
+		if s, ok := v.(Scanner); ok {
+			if err := s.ScanString(c); err != nil {
+				return errors.Wrapf(err, "Scan(%T) (ScanString)", e)
+			}
+
+			return nil
+		}
+
+		if s, ok := v.(encoding.TextUnmarshaler); ok {
+			if err := s.UnmarshalText([]byte(c)); err != nil {
+				return errors.Wrapf(err, "Scan(%T) (UnmarshalText)", e)
+			}
+
+			return nil
+		}
+
+		if s, ok := v.(json.Unmarshaler); ok {
+			if c == "" {
+				return nil
+			}
+
+			if err := s.UnmarshalJSON([]byte(c)); err != nil {
+				return errors.Wrapf(err, "Scan(%T) (UnmarshalJSON)", e)
+			}
+
+			return nil
+		}
+
+		switch el := p.Elem(); el.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(o.number(c), 10, el.Type().Bits())
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			el.SetInt(n)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(o.number(c), 10, el.Type().Bits())
+			if err != nil {
+				return errors.Wrapf(err, "Scan(%T)", e)
+			}
+			el.SetUint(n)
+			return nil
+		case reflect.Slice:
+			return scanSlice(c, el, o)
+		}
+
+		return fmt.Errorf("can't scan into %T", e)
+	}
+
+	return nil
+}
+
+func primaryName(name string) string {
+	return strings.Split(name, "|")[0]
+}
+
+func matchAny(match func(a, b string) bool, s, name string) bool {
+	for _, alt := range strings.Split(name, "|") {
+		if match(s, alt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(a []string, s string) bool {
+	for _, e := range a {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type field struct {
+	name  string
+	index []int
+	opts  []string
+	// for fields spread across several columns, like "First+Last", which of
+	// them this is
+	part, parts int
+}
+
+func (f field) has(opt string) bool {
+	return contains(f.opts, opt)
+}
+
+func (f field) get(key string) (string, bool) {
+	for _, o := range f.opts {
+		if strings.HasPrefix(o, key+":") {
+			return strings.TrimPrefix(o, key+":"), true
+		}
+	}
+
+	return "", false
+}
+
+func mapColumnNamesToFieldIndexes(t reflect.Type) ([]string, []field) {
+	a := make([]string, 0)
+	m := make([]field, 0)
+
+	mapFields(t, nil, &a, &m)
+
+	return a, m
+}
+
+func mapFields(t reflect.Type, prefix []int, a *[]string, m *[]field) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		index := append(append([]int{}, prefix...), i)
+
+		t, ok := f.Tag.Lookup("xlsx")
+
+		p := strings.Split(t, ",")
+
+		// embedded structs without a column name of their own, and nested
+		// structs tagged inline, contribute their fields' columns instead
+		if f.Type.Kind() == reflect.Struct && ((f.Anonymous && p[0] == "") || contains(p[1:], "inline")) {
+			mapFields(f.Type, index, a, m)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		// a number format can contain commas itself, so it takes the rest of
+		// the tag
+		for j, o := range p[1:] {
+			if strings.HasPrefix(o, "format:") || strings.HasPrefix(o, "format=") {
+				p = append(p[:j+1], "format:"+strings.Join(append([]string{o[7:]}, p[j+2:]...), ","))
+				break
+			}
+		}
+
+		cols := strings.Split(p[0], "+")
+
+		for j, col := range cols {
+			fi := field{name: primaryName(col), index: index, opts: p[1:]}
+			if len(cols) > 1 {
+				fi.part, fi.parts = j, len(cols)
+			}
+
+			// a raw field usually shares its column with another field, so
+			// only list each column once
+			seen := false
+			for _, n := range *a {
+				seen = seen || primaryName(n) == fi.name
+			}
+			if !seen {
+				*a = append(*a, col)
+			}
+
+			*m = append(*m, fi)
+		}
+	}
+}
+
+type ColumnInfo struct {
+	Name      string
+	FieldName string
+	Type      reflect.Type
+	Options   []string
+}
+
+// ColumnsOf lists the columns a struct maps to, in declaration order, without
+// needing a sheet. Fields of nested structs have dotted field names.
+func ColumnsOf(v interface{}) []ColumnInfo {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	_, fields := mapColumnNamesToFieldIndexes(t)
+
+	res := make([]ColumnInfo, len(fields))
+
+	for i, f := range fields {
+		name, ft := fieldPath(t, f.index)
+
+		res[i] = ColumnInfo{
+			Name:      f.name,
+			FieldName: name,
+			Type:      ft,
+			Options:   append([]string{}, f.opts...),
+		}
+	}
+
+	return res
+}
+
+// fieldPath gives the dotted name and the type of the field at index in t.
+func fieldPath(t reflect.Type, index []int) (string, reflect.Type) {
+	var path []string
+
+	for _, j := range index {
+		sf := t.Field(j)
+		path = append(path, sf.Name)
+		t = sf.Type
+	}
+
+	return strings.Join(path, "."), t
+}
+
+// duplicateColumns complains about two fields reading the same column, which
+// would otherwise silently overwrite each other. Raw fields and the two ends
+// of a range are meant to share their column.
+func duplicateColumns(t reflect.Type, fields []field) error {
+	seen := make(map[string][]int)
+
+	for _, f := range fields {
+		if f.has("raw") {
+			continue
+		}
+
+		if end, ok := f.get("range"); ok {
+			if prev, ok := seen[f.name+"\x00"+end]; ok {
+				a, _ := fieldPath(t, prev)
+				b, _ := fieldPath(t, f.index)
+				return errors.Errorf("duplicateColumns: %s and %s are both the %s of range column %q", a, b, end, f.name)
+			}
+
+			seen[f.name+"\x00"+end] = f.index
+
+			continue
+		}
+
+		if prev, ok := seen[f.name]; ok {
+			a, _ := fieldPath(t, prev)
+			b, _ := fieldPath(t, f.index)
+			return errors.Errorf("duplicateColumns: %s and %s are both tagged with column %q", a, b, f.name)
+		}
+
+		seen[f.name] = f.index
+	}
+
+	return nil
+}
+
+type Adapter struct {
+	s      *xlsx.Sheet
+	typ    reflect.Type
+	fields []field
+	cols   map[string]int
+	width  int
+	header int
+	row    int
+	opts   options
+	last   map[string]string
+	done   bool
+	scans  map[string]func(s string, dst reflect.Value) error
+	// display forms of each field's values, by column name
+	aliases map[string]map[string]string
+	tmpl    *xlsx.Row
+}
+
+func newAdapter(s *xlsx.Sheet, typ reflect.Type, opts ...Option) (*Adapter, error) {
+	o := newOptions(opts)
+
+	names, fields := mapColumnNamesToFieldIndexes(typ)
+	if len(names) == 0 {
+		return nil, errors.Errorf("newAdapter: couldn't find column names in struct tags")
+	}
+
+	if err := duplicateColumns(typ, fields); err != nil {
+		return nil, errors.Wrap(err, "newAdapter")
+	}
+
+	row, cols, err := indexedColumns(names)
+	if err != nil {
+		return nil, errors.Wrap(err, "newAdapter")
+	}
+
+	if cols != nil {
+		// nothing to detect
+	} else if o.profile != nil {
+		if o.profile.HeaderRow < 0 || o.profile.HeaderRow >= len(s.Rows) {
+			return nil, errors.Errorf("newAdapter: profile header row %d is out of range; sheet has %d rows", o.profile.HeaderRow, len(s.Rows))
+		}
+
+		row, cols = o.profile.HeaderRow, o.profile.columns()
+	} else if o.headerRow >= 0 {
+		if o.headerRow >= len(s.Rows) {
+			return nil, errors.Errorf("newAdapter: header row %d is out of range; sheet has %d rows", o.headerRow, len(s.Rows))
+		}
+
+		var above *xlsx.Row
+		if o.headerRow > 0 {
+			above = s.Rows[o.headerRow-1]
+		}
+
+		row, cols = o.headerRow, findBelow(above, s.Rows[o.headerRow], o.header, names...)
+	} else {
+		row, cols = findHeader(s, o.headerLimit, o, names...)
+	}
+
+	if missing := missingColumns(fields, cols); len(missing) > 0 {
+		return nil, errors.Errorf("newAdapter: couldn't find some required columns: %s", strings.Join(missing, ", "))
+	}
+
+	var width int
+	for _, c := range cols {
+		if c > width {
+			width = c
+		}
+	}
+
+	aliases := make(map[string]map[string]string)
+	for _, f := range fields {
+		if m := fieldAliases(typ.FieldByIndex(f.index).Type, f, o); m != nil {
+			aliases[f.name] = m
+		}
+	}
+
+	return &Adapter{
+		s:       s,
+		typ:     typ,
+		fields:  fields,
+		cols:    cols,
+		width:   width,
+		header:  row,
+		row:     row,
+		opts:    o,
+		aliases: aliases,
+	}, nil
+}
+
+// indexedColumns handles headerless sheets, where every column is tagged
+// with its position like "#3" rather than a name. Data then starts at the
+// first row, so the header row is -1.
+func indexedColumns(names []string) (int, map[string]int, error) {
+	cols := make(map[string]int)
+
+	var named, indexed string
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "#") {
+			if named == "" {
+				named = name
+			}
+
+			continue
+		}
+
+		if indexed == "" {
+			indexed = name
+		}
+
+		n, err := strconv.Atoi(name[1:])
+		if err != nil || n < 0 {
+			return 0, nil, errors.Errorf("indexedColumns: invalid column index %q", name)
+		}
+
+		cols[name] = n
+	}
+
+	if len(cols) == 0 {
+		return 0, nil, nil
+	}
+
+	if named != "" {
+		return 0, nil, errors.Errorf("indexedColumns: can't mix column indexes like %q with column names like %q", indexed, named)
+	}
+
+	return -1, cols, nil
+}
+
+func missingColumns(fields []field, cols map[string]int) []string {
+	var missing []string
+
+	for _, f := range fields {
+		if _, ok := cols[f.name]; ok || f.has("optional") || contains(missing, f.name) {
+			continue
+		}
+
+		missing = append(missing, f.name)
+	}
+
+	return missing
+}
+
+// MatchesStruct runs header detection for v's columns against s, reporting
+// whether every required column was found and, if not, which were missing.
+func MatchesStruct(s *xlsx.Sheet, v interface{}, opts ...Option) (bool, []string) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return false, nil
+	}
+
+	o := newOptions(opts)
+
+	names, fields := mapColumnNamesToFieldIndexes(t)
+	if len(names) == 0 {
+		return false, nil
+	}
+
+	_, cols := findHeader(s, o.headerLimit, o, names...)
+
+	missing := missingColumns(fields, cols)
+
+	return len(missing) == 0, missing
+}
+
+func NewAdapter(s *xlsx.Sheet, v interface{}, opts ...Option) (*Adapter, error) {
+	a, err := newAdapter(s, reflect.TypeOf(v), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewAdapter")
+	}
+
+	return a, nil
+}
+
+func NewAdapterAtRow(s *xlsx.Sheet, v interface{}, headerRow int, opts ...Option) (*Adapter, error) {
+	a, err := newAdapter(s, reflect.TypeOf(v), append(opts, WithHeaderRow(headerRow))...)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewAdapterAtRow")
+	}
+
+	return a, nil
+}
+
+func newAdapterForSheet(doc *xlsx.File, name string, typ reflect.Type, opts ...Option) (*Adapter, error) {
+	s, err := sheet(doc, name, newOptions(opts).match)
+	if err != nil {
+		return nil, errors.Wrap(err, "newAdapterForSheet")
+	}
+
+	return newAdapter(s, typ, opts...)
+}
+
+func NewAdapterForSheet(doc *xlsx.File, name string, v interface{}, opts ...Option) (*Adapter, error) {
+	a, err := newAdapterForSheet(doc, name, reflect.TypeOf(v), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewAdapterForSheet")
+	}
+
+	return a, nil
+}
+
+func (r *Adapter) HeaderRow() int {
+	return r.header
+}
+
+func (r *Adapter) Sheet() *xlsx.Sheet {
+	return r.s
+}
+
+// RowIndex is the zero-based index in the sheet of the row Next moved to.
+func (r *Adapter) RowIndex() int {
+	return r.row
+}
+
+// CurrentRow gives the row Next moved to, or nil if there isn't one yet.
+func (r *Adapter) CurrentRow() *xlsx.Row {
+	if r.row < 0 {
+		return nil
+	}
+
+	return r.s.Rows[r.row]
+}
+
+func (r *Adapter) Columns() map[string]int {
+	m := make(map[string]int, len(r.cols))
+
+	for k, v := range r.cols {
+		m[k] = v
+	}
+
+	return m
+}
+
+// MappingProfile records where an Adapter found its header and columns, so
+// that the same layout can be reused with WithProfile without detecting it
+// again.
+type MappingProfile struct {
+	HeaderRow int            `json:"header_row"`
+	Columns   map[string]int `json:"columns"`
+}
+
+func (p MappingProfile) columns() map[string]int {
+	m := make(map[string]int, len(p.Columns))
+
+	for k, v := range p.Columns {
+		m[k] = v
+	}
+
+	return m
+}
+
+func (r *Adapter) ExportProfile() MappingProfile {
+	return MappingProfile{HeaderRow: r.header, Columns: r.Columns()}
+}
+
+// SetScanner makes Read use fn for a column in place of the usual scanning
+// based on the field's type. The cell is still trimmed, defaulted and so on
+// according to the field's tag first.
+func (r *Adapter) SetScanner(column string, fn func(s string, dst reflect.Value) error) {
+	if r.scans == nil {
+		r.scans = make(map[string]func(s string, dst reflect.Value) error)
+	}
+
+	for _, f := range r.fields {
+		if r.opts.match(f.name, column) {
+			column = f.name
+			break
+		}
+	}
+
+	r.scans[column] = fn
+}
+
+func (r *Adapter) fieldOptions(f field) options {
+	o := r.opts
+	o.scanFunc = r.scans[f.name]
+	o.aliases = r.aliases[f.name]
+	return o
+}
+
+func (r *Adapter) Next() bool {
+	if r.done {
+		return false
+	}
+
+	for blank := 0; r.row < len(r.s.Rows)-1; {
+		r.row++
+
+		if !isBlank(r.s.Rows[r.row]) {
+			return true
+		}
+
+		if r.opts.skipped != nil {
+			r.opts.skipped(r.row)
+		}
+
+		if blank++; r.opts.maxBlankRows > 0 && blank >= r.opts.maxBlankRows {
+			r.done = true
+			break
+		}
+	}
+
+	return false
+}
+
+// RowCount gives the number of rows Next will yield from the top of the
+// sheet, regardless of how far through it the adapter already is.
+func (r *Adapter) RowCount() int {
+	n := 0
+
+	for i, blank := r.header+1, 0; i < len(r.s.Rows); i++ {
+		if !isBlank(r.s.Rows[i]) {
+			n, blank = n+1, 0
+			continue
+		}
+
+		if blank++; r.opts.maxBlankRows > 0 && blank >= r.opts.maxBlankRows {
+			break
+		}
+	}
+
+	return n
+}
+
+func (r *Adapter) Read(out interface{}) error {
+	p := reflect.ValueOf(out)
+	if typ := reflect.PtrTo(r.typ); p.Type() != typ {
+		return errors.Errorf("Adapter.Read: expected out to be %s; was instead %s", typ, p.Type())
+	}
+
+	// adapters for sheets without a header start before the first row
+	if r.row < 0 {
+		return errors.Errorf("Adapter.Read: no current row; Next hasn't been called")
+	}
+
+	return r.read(p.Elem(), func(e RowError) error {
+		return errors.Wrap(e, "Adapter.Read")
+	})
+}
+
+// read scans the current row into v, passing any field that fails to fail.
+// Reading stops if fail returns an error.
+func (r *Adapter) read(v reflect.Value, fail func(e RowError) error) error {
+	cells := r.s.Rows[r.row].Cells
+
+	var parts []string
+	partCell := ""
+
+	for _, f := range r.fields {
+		i, ok := r.cols[f.name]
+
+		// a field spread across several columns reads them all, joined
+		// with spaces, once it gets to the last one
+		if f.parts > 0 {
+			if ok {
+				partCell = xlsx.GetCellIDStringFromCoords(i, r.row)
+			}
+
+			if ok && len(cells) > i {
+				if c := strings.TrimSpace(r.opts.scanValue(cells[i], v.FieldByIndex(f.index).Type())); c != "" {
+					parts = append(parts, c)
+
+					if r.last == nil {
+						r.last = make(map[string]string)
+					}
+
+					r.last[f.name] = c
+				}
+			}
+
+			if f.part < f.parts-1 {
+				continue
+			}
+
+			c, cell := strings.Join(parts, " "), partCell
+			parts, partCell = nil, ""
+
+			if err := readField(v, f, c, r.fieldOptions(f)); err != nil {
+				if err := fail(RowError{Row: r.row, Cell: cell, Column: f.name, Value: c, Err: err}); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		c, shown := "", ""
+
+		if len(cells) > i {
+			c, shown = r.opts.scanValue(cells[i], v.FieldByIndex(f.index).Type()), r.opts.value(cells[i])
+		}
+
+		if strings.TrimSpace(c) != "" {
+			if r.last == nil {
+				r.last = make(map[string]string)
+			}
+
+			r.last[f.name] = c
+		} else if f.has("fill") {
+			c, shown = r.last[f.name], r.last[f.name]
+		} else if r.opts.mergedFill {
+			c, shown = r.mergedValue(i, v.FieldByIndex(f.index).Type())
+		}
+
+		if err := readField(v, f, c, r.fieldOptions(f)); err != nil {
+			if err := fail(RowError{Row: r.row, Cell: xlsx.GetCellIDStringFromCoords(i, r.row), Column: f.name, Value: shown, Err: err}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RowError is an error reading one field of a row. Row is the zero-based
+// index of the row in the sheet, like RowIndex, but it's given 1-based in the
+// message to match the row number in Cell.
+type RowError struct {
+	Row    int
+	Cell   string
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e RowError) Error() string {
+	if e.Row < 0 {
+		return e.Err.Error()
+	}
+
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %s", e.Row+1, e.Err)
+	}
+
+	if e.Cell == "" {
+		return fmt.Sprintf("row %d, column %q, value %q: %s", e.Row+1, e.Column, e.Value, e.Err)
+	}
+
+	return fmt.Sprintf("cell %s (row %d, column %q, value %q): %s", e.Cell, e.Row+1, e.Column, e.Value, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// Validate reads every data row of a sheet into a throwaway value of v's type
+// and reports every field that fails to scan, without keeping any results.
+func Validate(doc *xlsx.File, name string, v interface{}, opts ...Option) []RowError {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return []RowError{{Row: -1, Err: errors.Errorf("Validate: expected v to be struct or pointer to struct; was instead %T", v)}}
+	}
+
+	ad, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return []RowError{{Row: -1, Err: errors.Wrap(err, "Validate: couldn't construct adapter")}}
+	}
+
+	var res []RowError
+
+	for ad.Next() {
+		_ = ad.read(reflect.New(t).Elem(), func(e RowError) error {
+			res = append(res, e)
+			return nil
+		})
+	}
+
+	return res
+}
+
+// mergedValue gives the value to scan into a field of type t, and the value
+// as it's displayed, from a cell merged down over column col of this row.
+func (r *Adapter) mergedValue(col int, t reflect.Type) (string, string) {
+	for j := r.row - 1; j > r.header; j-- {
+		if cells := r.s.Rows[j].Cells; col < len(cells) && cells[col].VMerge >= r.row-j {
+			return r.opts.scanValue(cells[col], t), r.opts.value(cells[col])
+		}
+	}
+
+	return "", ""
+}
+
+func (r *Adapter) EmptyColumns() []string {
+	var a []string
+
+	for name := range r.cols {
+		if r.last[name] == "" {
+			a = append(a, name)
+		}
+	}
+
+	sort.Slice(a, func(i, j int) bool { return r.cols[a[i]] < r.cols[a[j]] })
+
+	return a
+}
+
+func (r *Adapter) warnEmptyColumns() {
+	if r.opts.warn == nil {
+		return
+	}
+
+	for _, name := range r.EmptyColumns() {
+		r.opts.warn(fmt.Sprintf("column %q had no data in any row", name))
+	}
+}
+
+// enum specs map cell text to field values, like "open=1|closed=2"
+func parseEnum(spec string) [][2]string {
+	var a [][2]string
+
+	for _, e := range strings.Split(spec, "|") {
+		if kv := strings.SplitN(e, "=", 2); len(kv) == 2 {
+			a = append(a, [2]string{kv[0], kv[1]})
+		}
+	}
+
+	return a
+}
+
+func enumValue(spec, key string) (string, error) {
+	var keys []string
+
+	key = normalizeEnum(key)
+
+	for _, kv := range parseEnum(spec) {
+		if normalizeEnum(kv[0]) == key {
+			return kv[1], nil
+		}
+
+		keys = append(keys, kv[0])
+	}
+
+	return "", errors.Errorf("enumValue: %q isn't one of %s", key, strings.Join(keys, ", "))
+}
+
+func normalizeEnum(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func enumKey(spec, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	for _, kv := range parseEnum(spec) {
+		if kv[1] == value {
+			return kv[0], nil
+		}
+	}
+
+	return "", errors.Errorf("enumKey: no name for value %q in %q", value, spec)
+}
+
+func readField(v reflect.Value, f field, c string, o options) error {
+	if !f.has("raw") {
+		c = prepareCell(c, v.FieldByIndex(f.index).Type())
+	}
+
+	if cutset, ok := f.get("trim"); ok {
+		c = strings.TrimSpace(strings.Trim(c, cutset))
+	}
+
+	if f.has("presence") {
+		c = strconv.FormatBool(c != "")
+	}
+
+	if f.has("grouped") {
+		o.grouped = true
+	}
+
+	if f.has("json") {
+		o.json = true
+	}
+
+	if d, ok := f.get("delim"); ok {
+		o.delimiter = d
+	}
+
+	if d, ok := f.get("default"); ok && c == "" {
+		c = d
+	}
+
+	// a cell like "5-10" can be spread across a pair of fields tagged
+	// range:min and range:max
+	if end, ok := f.get("range"); ok && c != "" {
+		var r Range
+		if err := r.ScanString(c); err != nil {
+			return err
+		}
+
+		if end == "max" {
+			c = strconv.Itoa(r[1])
+		} else {
+			c = strconv.Itoa(r[0])
+		}
+	}
+
+	if values, ok := f.get("values"); ok {
+		bindEnum(v.FieldByIndex(f.index), strings.Split(values, "|"))
+	}
+
+	if s, ok := o.aliases[normalizeEnum(c)]; ok && c != "" {
+		c = s
+	}
+
+	if spec, ok := f.get("enum"); ok && c != "" {
+		v, err := enumValue(spec, c)
+		if err != nil {
+			return err
+		}
+
+		c = v
+	}
+
+	fv := v.FieldByIndex(f.index)
+
+	if o.scanFunc != nil {
+		if err := o.scanFunc(c, fv); err != nil {
+			return err
+		}
+	} else if err := scan(c, fv.Addr().Interface(), o); err != nil {
+		return err
+	}
+
+	if c == "" {
+		return nil
+	}
+
+	return checkRange(fv, f)
+}
+
+// checkRange enforces the min and max tag options on numeric fields.
+func checkRange(v reflect.Value, f field) error {
+	lo, hasMin := f.get("min")
+	hi, hasMax := f.get("max")
+	if !hasMin && !hasMax {
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	var n float64
+
+	if d, ok := v.Interface().(interface{ Float64() float64 }); ok {
+		n = d.Float64()
+	} else {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = v.Float()
+		default:
+			return errors.Errorf("checkRange: can't apply min/max to %s", v.Type())
+		}
+	}
+
+	if hasMin {
+		m, err := strconv.ParseFloat(lo, 64)
+		if err != nil {
+			return errors.Wrapf(err, "checkRange: invalid min %q", lo)
+		}
+
+		if n < m {
+			return errors.Errorf("checkRange: %v is less than min %v", n, m)
+		}
+	}
+
+	if hasMax {
+		m, err := strconv.ParseFloat(hi, 64)
+		if err != nil {
+			return errors.Wrapf(err, "checkRange: invalid max %q", hi)
+		}
+
+		if n > m {
+			return errors.Errorf("checkRange: %v is greater than max %v", n, m)
+		}
+	}
+
+	return nil
+}
+
+func ReadRecord(doc *xlsx.File, name string, out interface{}, opts ...Option) error {
+	p := reflect.ValueOf(out)
+	if p.Kind() != reflect.Ptr {
+		return errors.Errorf("ReadRecord: expected out to be pointer; was instead %s", p.Kind())
+	}
+
+	v := p.Elem()
+	if v.Kind() != reflect.Struct {
+		return errors.Errorf("ReadRecord: expected out to be pointer to struct; was instead pointer to %s", v.Kind())
+	}
+
+	names, fields := mapColumnNamesToFieldIndexes(v.Type())
+	if len(names) == 0 {
+		return errors.Errorf("ReadRecord: couldn't find field names in struct tags")
+	}
+
+	o := newOptions(opts)
+
+	s, err := sheet(doc, name, o.match)
+	if err != nil {
+		return errors.Wrap(err, "ReadRecord")
+	}
+
+	values := make(map[string]string)
+
+	for _, r := range s.Rows {
+		if len(r.Cells) == 0 {
+			continue
+		}
+
+		for _, n := range names {
+			if _, ok := values[primaryName(n)]; ok {
+				continue
+			}
+
+			if matchAny(o.match, r.Cells[0].String(), n) {
+				values[primaryName(n)] = ""
+
+				if len(r.Cells) > 1 {
+					values[primaryName(n)] = o.value(r.Cells[1])
+				}
+			}
+		}
+	}
+
+	var missing []string
+	for _, f := range fields {
+		if _, ok := values[f.name]; !ok && !f.has("optional") && !contains(missing, f.name) {
+			missing = append(missing, f.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("ReadRecord: couldn't find some required fields: %s", strings.Join(missing, ", "))
+	}
+
+	for _, f := range fields {
+		c, ok := values[f.name]
+		if !ok {
+			continue
+		}
+
+		fo := o
+		fo.aliases = fieldAliases(v.FieldByIndex(f.index).Type(), f, o)
+
+		if err := readField(v, f, c, fo); err != nil {
+			return errors.Wrapf(err, "ReadRecord: couldn't read field %q", f.name)
+		}
+	}
+
+	return nil
+}
+
+func ReadAll(doc *xlsx.File, name string, out interface{}, opts ...Option) error {
+	p := reflect.ValueOf(out)
+	if p.Kind() != reflect.Ptr {
+		return errors.Errorf("ReadAll: expected out to be pointer; was instead %s", p.Kind())
+	}
+
+	s := p.Elem()
+	if s.Kind() != reflect.Slice {
+		return errors.Errorf("ReadAll: expected out to be pointer to slice; was instead pointer to %s", s.Kind())
+	}
+
+	t := s.Type().Elem()
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("ReadAll: expected out to be pointer to slice of struct; was instead pointer to slice of %s", t.Kind())
+	}
+
+	rd, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return errors.Wrap(err, "ReadAll: couldn't construct adapter")
+	}
+
+	return rd.readAll(s)
+}
+
+// ReadAllMatching reads every sheet whose name matches pattern, in workbook
+// order, appending all of their rows to out. WithSheetFunc can be used to
+// find out which rows came from which sheet.
+func ReadAllMatching(doc *xlsx.File, pattern *regexp.Regexp, out interface{}, opts ...Option) error {
+	p := reflect.ValueOf(out)
+	if p.Kind() != reflect.Ptr {
+		return errors.Errorf("ReadAllMatching: expected out to be pointer; was instead %s", p.Kind())
+	}
+
+	s := p.Elem()
+	if s.Kind() != reflect.Slice {
+		return errors.Errorf("ReadAllMatching: expected out to be pointer to slice; was instead pointer to %s", s.Kind())
+	}
+
+	t := s.Type().Elem()
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("ReadAllMatching: expected out to be pointer to slice of struct; was instead pointer to slice of %s", t.Kind())
+	}
+
+	o := newOptions(opts)
+
+	var found []string
+
+	for _, sh := range doc.Sheets {
+		if !pattern.MatchString(sh.Name) {
+			continue
+		}
+
+		found = append(found, sh.Name)
+
+		rd, err := newAdapter(sh, t, opts...)
+		if err != nil {
+			return errors.Wrapf(err, "ReadAllMatching: couldn't construct adapter for sheet %q", sh.Name)
+		}
+
+		n := s.Len()
+
+		if err := rd.readAll(s); err != nil {
+			return errors.Wrapf(err, "ReadAllMatching: sheet %q", sh.Name)
+		}
+
+		if o.sheetFunc != nil {
+			o.sheetFunc(sh.Name, n, s.Len())
+		}
+	}
+
+	if len(found) == 0 {
+		return errors.Errorf("ReadAllMatching: no sheets matching %q", pattern)
+	}
+
+	return nil
+}
+
+// readAll appends every row to s, which must be a slice of the adapter's
+// type.
+func (r *Adapter) readAll(s reflect.Value) error {
+	t := r.typ
+
+	key, keyName := -1, ""
+	seen := make(map[string]int)
+
+	if r.opts.uniqueKey != "" {
+		if f, ok := r.column(r.opts.uniqueKey); ok {
+			if i, ok := r.cols[f.name]; ok {
+				key, keyName = i, f.name
+			}
+		}
+
+		if key == -1 {
+			return errors.Errorf("ReadAll: couldn't find unique key column %q", r.opts.uniqueKey)
+		}
+	}
+
+	for r.Next() {
+		e := reflect.New(t)
+
+		if err := r.Read(e.Interface()); err != nil {
+			return errors.Wrapf(err, "ReadAll: couldn't read row %d of %d", r.row, len(r.s.Rows))
+		}
+
+		if key != -1 {
+			if cells := r.s.Rows[r.row].Cells; key < len(cells) {
+				if k := strings.TrimSpace(normalizeCell(r.opts.value(cells[key]))); k != "" {
+					if prev, ok := seen[k]; ok {
+						return errors.Errorf("ReadAll: duplicate %s %q in rows %d and %d", keyName, k, prev+1, r.row+1)
+					}
+
+					seen[k] = r.row
+				}
+			}
+		}
+
+		s.Set(reflect.Append(s, reflect.Indirect(e)))
+	}
+
+	r.warnEmptyColumns()
+
+	return nil
+}
+
+func (r *Adapter) Each(out interface{}, fn func(i int) error) error {
+	p := reflect.ValueOf(out)
+	if typ := reflect.PtrTo(r.typ); p.Type() != typ {
+		return errors.Errorf("Adapter.Each: expected out to be %s; was instead %s", typ, p.Type())
+	}
+
+	for i := 0; r.Next(); i++ {
+		p.Elem().Set(reflect.Zero(r.typ))
+
+		if err := r.Read(out); err != nil {
+			return errors.Wrapf(err, "Adapter.Each: couldn't read row %d of %d", r.row, len(r.s.Rows))
+		}
+
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ReadEach(doc *xlsx.File, name string, out interface{}, fn func(i int) error, opts ...Option) error {
+	p := reflect.ValueOf(out)
+	if p.Kind() != reflect.Ptr {
+		return errors.Errorf("ReadEach: expected out to be pointer; was instead %s", p.Kind())
+	}
+
+	t := p.Type().Elem()
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("ReadEach: expected out to be pointer to struct; was instead pointer to %s", t.Kind())
+	}
+
+	rd, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return errors.Wrap(err, "ReadEach: couldn't construct adapter")
+	}
+
+	if err := rd.Each(out, fn); err != nil {
+		return errors.Wrap(err, "ReadEach")
+	}
+
+	rd.warnEmptyColumns()
+
+	return nil
+}
+
+func ReadPositional(doc *xlsx.File, name string, startRow int, out interface{}, opts ...Option) error {
+	p := reflect.ValueOf(out)
+	if p.Kind() != reflect.Ptr {
+		return errors.Errorf("ReadPositional: expected out to be pointer; was instead %s", p.Kind())
+	}
+
+	s := p.Elem()
+	if s.Kind() != reflect.Slice {
+		return errors.Errorf("ReadPositional: expected out to be pointer to slice; was instead pointer to %s", s.Kind())
+	}
+
+	t := s.Type().Elem()
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("ReadPositional: expected out to be pointer to slice of struct; was instead pointer to slice of %s", t.Kind())
+	}
+
+	o := newOptions(opts)
+
+	sh, err := sheet(doc, name, o.match)
+	if err != nil {
+		return errors.Wrap(err, "ReadPositional")
+	}
+
+	names, fields := mapColumnNamesToFieldIndexes(t)
+	if len(names) == 0 {
+		return errors.Errorf("ReadPositional: couldn't find column names in struct tags")
+	}
+
+	cols := make(map[string]int, len(names))
+	for i, n := range names {
+		cols[primaryName(n)] = i
+	}
+
+	rd := &Adapter{
+		s:      sh,
+		typ:    t,
+		fields: fields,
+		cols:   cols,
+		width:  len(names) - 1,
+		header: startRow - 1,
+		row:    startRow - 1,
+		opts:   o,
+	}
+
+	for rd.Next() {
+		e := reflect.New(t)
+
+		if err := rd.Read(e.Interface()); err != nil {
+			return errors.Wrapf(err, "ReadPositional: couldn't read row %d of %d", rd.row, len(rd.s.Rows))
+		}
+
+		s.Set(reflect.Append(s, reflect.Indirect(e)))
+	}
+
+	return nil
+}
+
+func (r *Adapter) Raw() map[string]string {
+	if r.row < 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(r.cols))
+
+	cells := r.s.Rows[r.row].Cells
+
+	for name, i := range r.cols {
+		if len(cells) > i {
+			m[name] = r.opts.value(cells[i])
+		} else {
+			m[name] = ""
+		}
+	}
+
+	return m
+}
+
+func ReadAllWithRaw(doc *xlsx.File, name string, out interface{}, opts ...Option) error {
+	p := reflect.ValueOf(out)
+	if p.Kind() != reflect.Ptr {
+		return errors.Errorf("ReadAllWithRaw: expected out to be pointer; was instead %s", p.Kind())
+	}
+
+	s := p.Elem()
+	if s.Kind() != reflect.Slice {
+		return errors.Errorf("ReadAllWithRaw: expected out to be pointer to slice; was instead pointer to %s", s.Kind())
+	}
+
+	w := s.Type().Elem()
+	if w.Kind() != reflect.Struct {
+		return errors.Errorf("ReadAllWithRaw: expected out to be pointer to slice of struct; was instead pointer to slice of %s", w.Kind())
+	}
+
+	vf, ok := w.FieldByName("Value")
+	if !ok || vf.Type.Kind() != reflect.Struct {
+		return errors.Errorf("ReadAllWithRaw: expected %s to have a struct field named Value", w)
+	}
+
+	rf, ok := w.FieldByName("Raw")
+	if !ok || rf.Type != reflect.TypeOf(map[string]string(nil)) {
+		return errors.Errorf("ReadAllWithRaw: expected %s to have a map[string]string field named Raw", w)
+	}
+
+	t := vf.Type
+
+	rd, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return errors.Wrap(err, "ReadAllWithRaw: couldn't construct adapter")
+	}
+
+	for rd.Next() {
+		e := reflect.New(w).Elem()
+
+		if err := rd.Read(e.FieldByIndex(vf.Index).Addr().Interface()); err != nil {
+			return errors.Wrapf(err, "ReadAllWithRaw: couldn't read row %d of %d", rd.row, len(rd.s.Rows))
+		}
+
+		e.FieldByIndex(rf.Index).Set(reflect.ValueOf(rd.Raw()))
+
+		s.Set(reflect.Append(s, e))
+	}
+
+	return nil
+}
+
+func (r *Adapter) Write(in interface{}) error {
+	return r.write(in, nil)
+}
+
+// WriteColumns is like Write, but only touches the cells of the named
+// columns, leaving the rest of the row as it was.
+func (r *Adapter) WriteColumns(in interface{}, columns ...string) error {
+	only := make(map[string]bool)
+
+	for _, column := range columns {
+		f, ok := r.column(column)
+		if !ok {
+			return errors.Errorf("Adapter.WriteColumns: couldn't find column %q", column)
+		}
+
+		only[f.name] = true
+	}
+
+	return r.write(in, only)
+}
+
+// column finds the first field, in the order they're declared, for a column
+// name, or failing that for a Go field name.
+func (r *Adapter) column(name string) (*field, bool) {
+	for i := range r.fields {
+		if r.opts.match(r.fields[i].name, name) {
+			return &r.fields[i], true
+		}
+	}
+
+	for i := range r.fields {
+		if r.typ.FieldByIndex(r.fields[i].index).Name == name {
+			return &r.fields[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// write writes in to the current row, limited to the fields in only if it's
+// not nil.
+func (r *Adapter) write(in interface{}, only map[string]bool) error {
+	p := reflect.ValueOf(in)
+	if p.Type() != r.typ {
+		return errors.Errorf("Adapter.Write: expected in to be %s; was instead %s", r.typ, p.Type())
+	}
+
+	if r.row < 0 {
+		return errors.Errorf("Adapter.Write: no current row; Next hasn't been called")
+	}
+
+	// Cell copies styles along when it extends a row, so make sure the row is
+	// wide enough before any field styles its own cell
+	Cell(r.s.Rows[r.row], r.width)
+
+	ranges := make(map[int]*[2]string)
+
+	for _, f := range r.fields {
+		if f.has("raw") || (only != nil && !only[f.name]) {
+			continue
+		}
+
+		i, ok := r.cols[f.name]
+		if !ok {
+			continue
+		}
+
+		// the two ends of a range share a cell, so they're written together
+		// once both are known
+		if end, ok := f.get("range"); ok {
+			var tmp xlsx.Cell
+			if err := writeCell(&tmp, p.FieldByIndex(f.index), r.opts); err != nil {
+				return errors.Wrap(err, "Adapter.Write")
+			}
+
+			if ranges[i] == nil {
+				ranges[i] = new([2]string)
+			}
+
+			if end == "max" {
+				ranges[i][1] = tmp.Value
+			} else {
+				ranges[i][0] = tmp.Value
+			}
+
+			continue
+		}
+
+		if err := writeField(Cell(r.s.Rows[r.row], i), p.FieldByIndex(f.index), f, r.opts); err != nil {
+			return errors.Wrap(err, "Adapter.Write")
+		}
+	}
+
+	for i, a := range ranges {
+		Cell(r.s.Rows[r.row], i).SetString(a[0] + "-" + a[1])
+	}
+
+	return nil
+}
+
+func writeField(c *xlsx.Cell, v reflect.Value, f field, o options) error {
+	if d, ok := f.get("delim"); ok {
+		o.delimiter = d
+	}
+
+	if f.has("json") {
+		o.json = true
+	}
+
+	if f.parts > 0 {
+		var tmp xlsx.Cell
+		if err := writeCell(&tmp, v, o); err != nil {
+			return err
+		}
+
+		split := splitWords
+		if name, ok := f.get("split"); ok {
+			if split, ok = Splitters[name]; !ok {
+				return errors.Errorf("couldn't write %q: no splitter named %q", f.name, name)
+			}
+		}
+
+		if a := split(tmp.Value, f.parts); f.part < len(a) {
+			c.SetString(a[f.part])
+		} else {
+			c.SetString("")
+		}
+
+		return nil
+	}
+
+	if spec, ok := f.get("enum"); ok {
+		var tmp xlsx.Cell
+		if err := writeCell(&tmp, v, o); err != nil {
+			return err
+		}
+
+		k, err := enumKey(spec, tmp.Value)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't write %q", f.name)
+		}
+
+		c.SetString(k)
+	} else if f.has("text") {
+		// stored as a string with the text format, so nothing strips the
+		// leading zeros off codes like "00123" when the sheet is edited
+		var tmp xlsx.Cell
+		if err := writeCell(&tmp, v, o); err != nil {
+			return err
+		}
+
+		c.SetString(tmp.Value)
+		c.NumFmt = "@"
+	} else if err := writeCell(c, v, o); err != nil {
+		return err
+	}
+
+	if format, ok := f.get("format"); ok {
+		c.NumFmt = format
+	}
+
+	if align, ok := f.get("align"); ok || f.has("wrap") {
+		st := *c.GetStyle()
+		if ok {
+			st.Alignment.Horizontal = align
+		}
+		st.Alignment.WrapText = st.Alignment.WrapText || f.has("wrap")
+		st.ApplyAlignment = true
+		c.SetStyle(&st)
+	}
+
+	if values, ok := f.get("values"); ok {
+		keys, err := dropList(v.Type(), strings.Split(values, "|"), o)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't build drop list for %q", f.name)
+		}
+
+		dv := xlsx.NewXlsxCellDataValidation(true)
+		if err := dv.SetDropList(keys); err != nil {
+			return errors.Wrapf(err, "couldn't build drop list for %q", f.name)
+		}
+
+		c.SetDataValidation(dv)
+	}
+
+	return nil
+}
+
+// Splitters holds named functions for the split tag option, which break a
+// value into n parts for a field written across several columns, like
+// `xlsx:"First+Last,split:name"`. Without the option, values are split on
+// whitespace with the last column taking whatever is left over.
+var Splitters = map[string]func(s string, n int) []string{}
+
+func splitWords(s string, n int) []string {
+	a := strings.Fields(s)
+	if len(a) > n {
+		a = append(a[:n-1], strings.Join(a[n-1:], " "))
+	}
+
+	return a
+}
+
+func writeCell(c *xlsx.Cell, v reflect.Value, o options) error {
+	if o.moneyFormat != "" {
+		switch e := v.Interface().(type) {
+		case Money:
+			writeMoney(c, e, o.moneyFormat)
+			return nil
+		case *Money:
+			if e != nil {
+				writeMoney(c, *e, o.moneyFormat)
+				return nil
+			}
+		}
+	}
+
+	if o.json {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			if v.IsNil() {
+				c.SetString("")
+				return nil
+			}
+		}
+
+		d, err := json.Marshal(v.Interface())
+		if err != nil {
+			return errors.Wrap(err, "writeCell (json)")
+		}
+
+		c.SetString(string(d))
+
+		return nil
+	}
+
+	switch e := v.Interface().(type) {
+	case nil:
+		c.SetString("")
+	case Percentage:
+		c.SetFloatWithFormat(float64(e), "0.00%")
+	case *Percentage:
+		if e == nil {
+			c.SetString("")
+		} else {
+			c.SetFloatWithFormat(float64(*e), "0.00%")
+		}
+	case string:
+		c.SetString(e)
+	case *string:
+		if e == nil {
+			c.SetString("")
+		} else {
+			c.SetString(*e)
+		}
+	case float64:
+		writeFloat(c, e, o)
+	case float32:
+		writeFloat(c, float64(e), o)
+	case *float64:
+		if e == nil {
+			c.SetString("")
+		} else {
+			writeFloat(c, *e, o)
+		}
+	case int:
+		c.SetInt(e)
+	case int8, int16, int32, int64:
+		c.SetInt64(v.Int())
+	case uint, uint8, uint16, uint32, uint64:
+		writeUint(c, v.Uint())
+	case *int:
+		if e == nil {
+			c.SetString("")
+		} else {
+			c.SetInt(*e)
+		}
+	case *int64:
+		if e == nil {
+			c.SetString("")
+		} else {
+			c.SetInt64(*e)
+		}
+	case bool:
+		c.SetBool(e)
+	case *bool:
+		if e == nil {
+			c.SetString("")
+		} else {
+			c.SetBool(*e)
+		}
+	case time.Time:
+		writeTime(c, e, o.dateFormat)
+	case *time.Time:
+		if e == nil {
+			c.SetString("")
+		} else {
+			writeTime(c, *e, o.dateFormat)
+		}
+	case interface{ Enum() string }:
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			c.SetString("")
+		} else {
+			c.SetString(e.Enum())
+		}
+	case fmt.Stringer:
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			c.SetString("")
+		} else {
+			c.SetString(e.String())
+		}
+	default:
+		// named types without any of the above methods are written by kind,
+		// the same way scan reads them
+		switch v.Kind() {
+		case reflect.String:
+			c.SetString(v.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			c.SetInt64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			writeUint(c, v.Uint())
+		case reflect.Slice:
+			return writeSlice(c, v, o)
+		case reflect.Ptr:
+			if v.IsNil() {
+				c.SetString("")
+				return nil
+			}
+
+			return writeCell(c, v.Elem(), o)
+		default:
+			return errors.Errorf("can't write field of type %T", e)
+		}
+	}
+
+	return nil
+}
+
+// writeUint writes n as a number if it fits in an int64, which is as far as
+// xlsx goes, and as text otherwise so that it isn't mangled.
+func writeUint(c *xlsx.Cell, n uint64) {
+	if n > math.MaxInt64 {
+		c.SetString(strconv.FormatUint(n, 10))
+		return
+	}
+
+	c.SetInt64(int64(n))
+}
+
+func writeSlice(c *xlsx.Cell, v reflect.Value, o options) error {
+	parts := make([]string, v.Len())
+
+	for i := range parts {
+		var tmp xlsx.Cell
+		if err := writeCell(&tmp, v.Index(i), o); err != nil {
+			return errors.Wrapf(err, "element %d", i)
+		}
+
+		parts[i] = tmp.Value
+	}
+
+	sep := o.delimiter
+	if strings.TrimSpace(sep) != "" {
+		sep += " "
+	}
+
+	c.SetString(strings.Join(parts, sep))
+
+	return nil
+}
+
+func writeTime(c *xlsx.Cell, t time.Time, format string) {
+	if t.IsZero() {
+		c.SetString("")
+		return
+	}
+
+	if format == "" {
+		c.SetDateTime(t)
+		return
+	}
+
+	c.SetDateWithOptions(t, xlsx.DateTimeOptions{Location: time.UTC, ExcelTimeFormat: format})
+}
+
+// writeFloat always stores a number, so it still works in formulas, and only
+// changes how it's displayed.
+func writeFloat(c *xlsx.Cell, f float64, o options) {
+	if o.precision < 0 {
+		c.SetFloat(f)
+	} else {
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'f', o.precision, 64), 64)
+
+		format := "0"
+		if o.precision > 0 {
+			format += "." + strings.Repeat("0", o.precision)
+		}
+
+		c.SetFloatWithFormat(f, format)
+	}
+
+	if o.numberFormat != "" {
+		c.NumFmt = o.numberFormat
+	}
+}
+
+func writeMoney(c *xlsx.Cell, m Money, format string) {
+	f, _ := strconv.ParseFloat(m.Round(), 64)
+	c.SetFloatWithFormat(f, format)
+}
+
+func dropList(t reflect.Type, values []string, o options) ([]string, error) {
+	keys := make([]string, len(values))
+
+	for i, s := range values {
+		v := reflect.New(t)
+		bindEnum(v.Elem(), values)
+
+		if err := scan(strings.TrimSpace(s), v.Interface(), o); err != nil {
+			return nil, errors.Wrapf(err, "dropList: couldn't scan %q", s)
+		}
+
+		var c xlsx.Cell
+		if err := writeCell(&c, v.Elem(), o); err != nil {
+			return nil, errors.Wrapf(err, "dropList: couldn't format %q", s)
+		}
+
+		keys[i] = c.Value
+	}
+
+	return keys, nil
+}
+
+// Enumerable is implemented by types with a fixed set of values, like enums.
+// Fields of these types can be read from any of the forms their values can be
+// displayed in, the same as fields with a values tag option.
+type Enumerable interface {
+	Values() []string
+}
+
+// fieldAliases maps every form a field's values can be displayed in, being
+// Code, String or Enum as well as the way they're written, to the value
+// itself. The values come from the field's values tag option, or failing that
+// from its type if that's Enumerable. It gives back nil if there are none.
+func fieldAliases(t reflect.Type, f field, o options) map[string]string {
+	var values []string
+
+	if s, ok := f.get("values"); ok {
+		values = strings.Split(s, "|")
+	} else {
+		base := t
+		for base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+
+		p := reflect.New(base)
+		for _, x := range []interface{}{p.Interface(), p.Elem().Interface()} {
+			if e, ok := x.(Enumerable); ok {
+				values = e.Values()
+				break
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string)
+
+	for _, s := range values {
+		s = strings.TrimSpace(s)
+
+		v := reflect.New(t)
+		bindEnum(v.Elem(), values)
+
+		if err := scan(s, v.Interface(), o); err != nil {
+			continue
+		}
+
+		forms := []string{s}
+
+		for _, x := range []interface{}{v.Interface(), v.Elem().Interface()} {
+			if e, ok := x.(interface{ Code() string }); ok {
+				forms = append(forms, e.Code())
+			}
+			if e, ok := x.(interface{ Enum() string }); ok {
+				forms = append(forms, e.Enum())
+			}
+			if e, ok := x.(fmt.Stringer); ok {
+				forms = append(forms, e.String())
+			}
+		}
+
+		for _, form := range forms {
+			if _, ok := m[normalizeEnum(form)]; !ok {
+				m[normalizeEnum(form)] = s
+			}
+		}
+	}
+
+	return m
+}
+
+func (r *Adapter) shade(color string) {
+	for _, c := range r.s.Rows[r.row].Cells {
+		st := *c.GetStyle()
+		st.Fill = *xlsx.NewFill(xlsx.Solid_Cell_Fill, color, color)
+		st.ApplyFill = true
+		c.SetStyle(&st)
+	}
+}
+
+func WriteAll(doc *xlsx.File, name string, in interface{}, opts ...Option) error {
+	p := reflect.ValueOf(in)
+	if p.Kind() != reflect.Slice {
+		return errors.Errorf("WriteAll: expected in to be slice; was instead %s", p.Kind())
+	}
+
+	t := p.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("WriteAll: expected in to be slice of struct or pointer to struct; was instead slice of %s", p.Type().Elem())
+	}
+
+	ad, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return errors.Wrap(err, "WriteAll: couldn't construct adapter")
+	}
+
+	if err := ad.useTemplate(); err != nil {
+		return errors.Wrap(err, "WriteAll")
+	}
+
+	// clearing the summary sheet would throw away the rows about to be
+	// written, so check before writing them
+	if ad.opts.summaryBy != "" {
+		if ad.opts.summarySheet == "" {
+			return errors.Errorf("WriteAll: no summary sheet name given")
+		}
+
+		if s, err := sheet(doc, ad.opts.summarySheet, ad.opts.match); err == nil && s == ad.s {
+			return errors.Errorf("WriteAll: summary sheet %q can't be the sheet being written", ad.opts.summarySheet)
+		}
+	}
 
-			if p.Type().Elem().Kind() == reflect.Ptr && p.Elem().IsNil() {
-				p.Elem().Set(reflect.New(p.Type().Elem().Elem()))
-				p = p.Elem()
-			}
+	// without WithInPlace every row written is a new one, so keeping hold of
+	// the old ones is enough to undo a failed write
+	rows, maxRow := append([]*xlsx.Row(nil), ad.s.Rows...), ad.s.MaxRow
 
-			v := p.Interface()
+	if !ad.opts.inPlace {
+		ad.s.Rows = ad.s.Rows[0 : ad.row+1]
+	}
 
-			if s, ok := v.(Scanner); ok {
-				if err := s.ScanString(c); err != nil {
-					return errors.Wrapf(err, "Scan(%T) (ScanString)", e)
-				}
+	if err := ad.writeRows(p, ad.header+1); err != nil {
+		if we, ok := err.(*WriteError); ok && !ad.opts.inPlace {
+			ad.s.Rows, ad.s.MaxRow = rows, maxRow
+			we.RolledBack = true
+		}
 
-				continue
-			}
+		return errors.Wrap(err, "WriteAll")
+	}
 
-			if s, ok := v.(encoding.TextUnmarshaler); ok {
-				if err := s.UnmarshalText([]byte(c)); err != nil {
-					return errors.Wrapf(err, "Scan(%T) (UnmarshalText)", e)
-				}
-			}
+	if ad.opts.exportSheet != "" {
+		if err := writeExportTime(doc, ad.opts.exportSheet, time.Now(), ad.opts); err != nil {
+			return errors.Wrap(err, "WriteAll")
+		}
+	}
 
-			return fmt.Errorf("can't scan into %T", e)
+	if ad.opts.summaryBy != "" {
+		if err := ad.writeSummary(doc, p); err != nil {
+			return errors.Wrap(err, "WriteAll")
 		}
 	}
 
 	return nil
 }
 
-func mapColumnNamesToFieldIndexes(t reflect.Type) ([]string, map[string]int) {
-	a := make([]string, 0)
-	m := make(map[string]int, 0)
+// writeSummary replaces the contents of the summary sheet with the number of
+// elements of p having each value of the summaryBy column, in the order the
+// values first appear.
+func (r *Adapter) writeSummary(doc *xlsx.File, p reflect.Value) error {
+	f, ok := r.column(r.opts.summaryBy)
+	if !ok {
+		return errors.Errorf("writeSummary: couldn't find column %q", r.opts.summaryBy)
+	}
 
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
+	var keys []string
+	counts := make(map[string]int)
 
-		t, ok := f.Tag.Lookup("xlsx")
-		if !ok {
+	for i := 0; i < p.Len(); i++ {
+		e := p.Index(i)
+		if r.opts.skipWrite != nil && r.opts.skipWrite(e.Interface()) {
 			continue
 		}
 
-		n := strings.Split(t, ",")[0]
+		if e.Kind() == reflect.Ptr {
+			e = e.Elem()
+		}
 
-		a = append(a, n)
+		var tmp xlsx.Cell
+		if err := writeField(&tmp, e.FieldByIndex(f.index), *f, r.opts); err != nil {
+			return errors.Wrap(err, "writeSummary")
+		}
 
-		m[n] = i
+		k := tmp.String()
+		if _, ok := counts[k]; !ok {
+			keys = append(keys, k)
+		}
+		counts[k]++
 	}
 
-	return a, m
-}
+	name := r.opts.summarySheet
 
-type Adapter struct {
-	s      *xlsx.Sheet
-	typ    reflect.Type
-	fields map[string]int
-	cols   map[string]int
-	width  int
-	row    int
-}
+	s, err := sheet(doc, name, r.opts.match)
+	if errors.Is(err, ErrSheetNotFound) {
+		if s, err = doc.AddSheet(name); err != nil {
+			return errors.Wrap(err, "writeSummary: couldn't add sheet")
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "writeSummary")
+	}
 
-func newAdapter(s *xlsx.Sheet, typ reflect.Type) (*Adapter, error) {
-	names, fields := mapColumnNamesToFieldIndexes(typ)
-	if len(names) == 0 {
-		return nil, errors.Errorf("newAdapter: couldn't find column names in struct tags")
+	s.Rows = s.Rows[:0]
+
+	h := s.AddRow()
+	h.AddCell().SetString(f.name)
+	h.AddCell().SetString("Count")
+
+	for _, k := range keys {
+		row := s.AddRow()
+		row.AddCell().SetString(k)
+		row.AddCell().SetInt(counts[k])
 	}
 
-	row, cols := FindHeader(s, 10, names...)
-	if len(cols) != len(fields) {
-		var missing []string
+	return nil
+}
 
-		for k := range fields {
-			if _, ok := cols[k]; !ok {
-				missing = append(missing, k)
-			}
+// writeExportTime records t against an "Exported" key in a key/value sheet,
+// in the layout ReadRecord understands, adding the sheet if it's missing.
+func writeExportTime(doc *xlsx.File, name string, t time.Time, o options) error {
+	s, err := sheet(doc, name, o.match)
+	if errors.Is(err, ErrSheetNotFound) {
+		if s, err = doc.AddSheet(name); err != nil {
+			return errors.Wrap(err, "writeExportTime: couldn't add sheet")
 		}
-
-		return nil, errors.Errorf("newAdapter: couldn't find some required columns: %s", strings.Join(missing, ", "))
+	} else if err != nil {
+		return errors.Wrap(err, "writeExportTime")
 	}
 
-	var width int
-	for _, c := range cols {
-		if c > width {
-			width = c
+	var r *xlsx.Row
+	for _, rr := range s.Rows {
+		if len(rr.Cells) > 0 && o.match(rr.Cells[0].String(), "Exported") {
+			r = rr
+			break
 		}
 	}
 
-	return &Adapter{
-		s:      s,
-		typ:    typ,
-		fields: fields,
-		cols:   cols,
-		width:  width,
-		row:    row,
-	}, nil
-}
+	if r == nil {
+		r = s.AddRow()
+		r.AddCell().SetString("Exported")
+	}
 
-func NewAdapter(s *xlsx.Sheet, v interface{}) (*Adapter, error) {
-	a, err := newAdapter(s, reflect.TypeOf(v))
-	if err != nil {
-		return nil, errors.Wrap(err, "NewAdapter")
+	for len(r.Cells) < 2 {
+		r.AddCell()
 	}
 
-	return a, nil
+	writeTime(r.Cells[1], t, o.dateFormat)
+
+	return nil
 }
 
-func newAdapterForSheet(doc *xlsx.File, name string, typ reflect.Type) (*Adapter, error) {
-	s, err := Sheet(doc, name)
-	if err != nil {
-		return nil, errors.Wrap(err, "newAdapterForSheet")
+// WriteAllFromTemplate is WriteAll with every written row styled like the
+// given template row, which is usually a styled sample data row.
+func WriteAllFromTemplate(doc *xlsx.File, name string, templateRow int, in interface{}, opts ...Option) error {
+	if err := WriteAll(doc, name, in, append(opts, WithTemplateRow(templateRow))...); err != nil {
+		return errors.Wrap(err, "WriteAllFromTemplate")
 	}
 
-	return newAdapter(s, typ)
+	return nil
 }
 
-func NewAdapterForSheet(doc *xlsx.File, name string, v interface{}) (*Adapter, error) {
-	a, err := newAdapterForSheet(doc, name, reflect.TypeOf(v))
-	if err != nil {
-		return nil, errors.Wrap(err, "NewAdapterForSheet")
+func AppendAll(doc *xlsx.File, name string, in interface{}, opts ...Option) error {
+	p := reflect.ValueOf(in)
+	if p.Kind() != reflect.Slice {
+		return errors.Errorf("AppendAll: expected in to be slice; was instead %s", p.Kind())
 	}
 
-	return a, nil
-}
+	t := p.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("AppendAll: expected in to be slice of struct or pointer to struct; was instead slice of %s", p.Type().Elem())
+	}
 
-func (r *Adapter) Next() bool {
-	if r.row >= len(r.s.Rows)-1 {
-		return false
+	ad, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return errors.Wrap(err, "AppendAll: couldn't construct adapter")
 	}
 
-	r.row++
+	if err := ad.useTemplate(); err != nil {
+		return errors.Wrap(err, "AppendAll")
+	}
 
-	for _, c := range r.s.Rows[r.row].Cells {
-		if c.String() != "" {
-			return true
+	last := ad.header
+	for i := len(ad.s.Rows) - 1; i > ad.header; i-- {
+		if !isBlank(ad.s.Rows[i]) {
+			last = i
+			break
 		}
 	}
 
-	return r.Next()
-}
-
-func (r *Adapter) Read(out interface{}) error {
-	p := reflect.ValueOf(out)
-	if typ := reflect.PtrTo(r.typ); p.Type() != typ {
-		return errors.Errorf("Adapter.Read: expected out to be %s; was instead %s", typ, p.Type())
+	if err := ad.writeRows(p, last+1); err != nil {
+		return errors.Wrap(err, "AppendAll")
 	}
 
-	arr := make([]interface{}, r.width+1)
-
-	v := p.Elem()
+	return nil
+}
 
-	for name, f := range r.fields {
-		arr[r.cols[name]] = v.Field(f).Addr().Interface()
+func AppendRow(doc *xlsx.File, name string, in interface{}, opts ...Option) error {
+	if in == nil {
+		return errors.Errorf("AppendRow: expected in to be struct or pointer to struct; was instead nil")
 	}
 
-	if err := Scan(r.s.Rows[r.row], arr...); err != nil {
-		return errors.Wrapf(err, "Adapter.Read: couldn't read row %d of %d", r.row, len(r.s.Rows))
+	p := reflect.New(reflect.SliceOf(reflect.TypeOf(in))).Elem()
+
+	if err := AppendAll(doc, name, reflect.Append(p, reflect.ValueOf(in)).Interface(), opts...); err != nil {
+		return errors.Wrap(err, "AppendRow")
 	}
 
 	return nil
 }
 
-func ReadAll(doc *xlsx.File, name string, out interface{}) error {
-	p := reflect.ValueOf(out)
-	if p.Kind() != reflect.Ptr {
-		return errors.Errorf("ReadAll: expected out to be pointer; was instead %s", p.Kind())
+// ClearData removes everything below the header row of a sheet. That's the
+// row given with WithHeaderRow or WithProfile, or otherwise the sheet's first
+// non-blank row; for sheets with a title above the header, use one of those
+// options or ClearDataFor.
+func ClearData(doc *xlsx.File, name string, opts ...Option) error {
+	o := newOptions(opts)
+
+	s, err := sheet(doc, name, o.match)
+	if err != nil {
+		return errors.Wrap(err, "ClearData")
 	}
 
-	s := p.Elem()
-	if s.Kind() != reflect.Slice {
-		return errors.Errorf("ReadAll: expected out to be pointer to slice; was instead pointer to %s", s.Kind())
+	header := o.headerRow
+	if o.profile != nil {
+		header = o.profile.HeaderRow
 	}
 
-	t := s.Type().Elem()
-	if t.Kind() != reflect.Struct {
-		return errors.Errorf("ReadAll: expected out to be pointer to slice of struct; was instead pointer to slice of %s", t.Kind())
+	if header < 0 {
+		header = firstNonBlank(s)
 	}
 
-	rd, err := newAdapterForSheet(doc, name, t)
-	if err != nil {
-		return errors.Wrap(err, "ReadAll: couldn't construct adapter")
+	if header < 0 || header >= len(s.Rows) {
+		return errors.Errorf("ClearData: couldn't find header row in sheet %q", name)
 	}
 
-	for rd.Next() {
-		e := reflect.New(t)
+	s.Rows = s.Rows[0 : header+1]
 
-		if err := rd.Read(e.Interface()); err != nil {
-			return errors.Wrapf(err, "ReadAll: couldn't read row %d of %d", rd.row, len(rd.s.Rows))
-		}
+	return nil
+}
 
-		s.Set(reflect.Append(s, reflect.Indirect(e)))
+// ClearDataFor is like ClearData, but finds the header row the same way
+// WriteAll does for the struct type of v.
+func ClearDataFor(doc *xlsx.File, name string, v interface{}, opts ...Option) error {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return errors.Errorf("ClearDataFor: expected v to be struct or pointer to struct; was instead %T", v)
+	}
+
+	ad, err := newAdapterForSheet(doc, name, t, opts...)
+	if err != nil {
+		return errors.Wrap(err, "ClearDataFor: couldn't construct adapter")
 	}
 
+	ad.s.Rows = ad.s.Rows[0 : ad.header+1]
+
 	return nil
 }
 
-func (r *Adapter) Write(in interface{}) error {
-	p := reflect.ValueOf(in)
-	if p.Type() != r.typ {
-		return errors.Errorf("Adapter.Write: expected in to be %s; was instead %s", r.typ, p.Type())
+func firstNonBlank(s *xlsx.Sheet) int {
+	for i, r := range s.Rows {
+		if !isBlank(r) {
+			return i
+		}
 	}
 
-	for name, f := range r.fields {
-		v := p.Field(f)
-		e := v.Interface()
+	return -1
+}
 
-		switch e := e.(type) {
-		case nil:
-			Cell(r.s.Rows[r.row], r.cols[name]).SetString("")
-		case string:
-			Cell(r.s.Rows[r.row], r.cols[name]).SetString(e)
-		case *string:
-			if e == nil {
-				Cell(r.s.Rows[r.row], r.cols[name]).SetString("")
-			} else {
-				Cell(r.s.Rows[r.row], r.cols[name]).SetString(*e)
-			}
-		case float64:
-			Cell(r.s.Rows[r.row], r.cols[name]).SetString(fmt.Sprintf("%v", e))
-		case interface{ Enum() string }:
-			if v.Kind() == reflect.Ptr && v.IsNil() {
-				Cell(r.s.Rows[r.row], r.cols[name]).SetString("")
-			} else {
-				Cell(r.s.Rows[r.row], r.cols[name]).SetString(e.Enum())
-			}
-		case fmt.Stringer:
-			if v.Kind() == reflect.Ptr && v.IsNil() {
-				Cell(r.s.Rows[r.row], r.cols[name]).SetString("")
-			} else {
-				Cell(r.s.Rows[r.row], r.cols[name]).SetString(e.String())
-			}
-		default:
-			return errors.Errorf("Adapter.Write: can't write field of type %T", e)
+func isBlank(r *xlsx.Row) bool {
+	for _, c := range r.Cells {
+		if c.String() != "" {
+			return false
 		}
 	}
 
-	return nil
+	return true
 }
 
-func WriteAll(doc *xlsx.File, name string, in interface{}) error {
-	p := reflect.ValueOf(in)
-	if p.Kind() != reflect.Slice {
-		return errors.Errorf("WriteAll: expected in to be slice; was instead %s", p.Kind())
+// WriteError reports how far writing got before failing. If RolledBack is
+// set, the sheet was restored to how it was before writing started.
+type WriteError struct {
+	Written    int
+	RolledBack bool
+	Err        error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("wrote %d rows before failing: %s", e.Written, e.Err)
+}
+
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// useTemplate holds on to the row picked with WithTemplateRow, since it may
+// well be one of the rows about to be overwritten.
+func (r *Adapter) useTemplate() error {
+	i := r.opts.templateRow
+	if i < 0 {
+		return nil
 	}
 
-	t := p.Type().Elem()
-	if t.Kind() != reflect.Struct {
-		return errors.Errorf("WriteAll: expected in to be slice of struct; was instead slice of %s", t.Kind())
+	if i >= len(r.s.Rows) {
+		return errors.Errorf("useTemplate: template row %d is out of range; sheet has %d rows", i, len(r.s.Rows))
 	}
 
-	ad, err := newAdapterForSheet(doc, name, t)
-	if err != nil {
-		return errors.Wrap(err, "WriteAll: couldn't construct adapter")
+	tmpl := &xlsx.Row{}
+	for _, c := range r.s.Rows[i].Cells {
+		n := *c
+		tmpl.Cells = append(tmpl.Cells, &n)
 	}
 
-	ad.s.Rows = ad.s.Rows[0 : ad.row+1]
+	r.tmpl = tmpl
+
+	return nil
+}
+
+func (r *Adapter) writeRows(p reflect.Value, start int) error {
+	n := 0
 
 	for i, j := 0, p.Len(); i < j; i++ {
-		ad.s.AddRow()
+		e := p.Index(i)
+		if e.Kind() == reflect.Ptr {
+			if e.IsNil() {
+				return &WriteError{Written: n, Err: errors.Errorf("element %d of %d is nil", i, j)}
+			}
+		}
+
+		if r.opts.skipWrite != nil && r.opts.skipWrite(e.Interface()) {
+			continue
+		}
+
+		if e.Kind() == reflect.Ptr {
+			e = e.Elem()
+		}
+
+		r.row = start + n
+		n++
+
+		for len(r.s.Rows) <= r.row {
+			r.s.AddRow()
+		}
+
+		if r.tmpl != nil {
+			CopyRowStyles(r.s.Rows[r.row], r.tmpl)
+		}
+
+		if err := r.Write(e.Interface()); err != nil {
+			return &WriteError{Written: n - 1, Err: errors.Wrapf(err, "couldn't write row %d of %d", r.row, j)}
+		}
 
-		ad.Next()
+		// writing a value resets the number format, so put the template's
+		// formats back afterwards
+		if r.tmpl != nil {
+			for k, c := range r.tmpl.Cells {
+				if c.NumFmt != "" && c.NumFmt != "general" {
+					Cell(r.s.Rows[r.row], k).NumFmt = c.NumFmt
+				}
+			}
+		}
 
-		if err := ad.Write(p.Index(i).Interface()); err != nil {
-			return errors.Wrapf(err, "WriteAll: couldn't write row %d of %d", ad.row, j)
+		if r.opts.shading != "" && n%2 == 0 {
+			r.shade(r.opts.shading)
 		}
 	}
 
 	return nil
 }
 
-func SetupSheet(doc *xlsx.File, name string, in interface{}) (*xlsx.Sheet, error) {
-	res, err := setupSheet(doc, name, reflect.TypeOf(in))
+func SetupSheet(doc *xlsx.File, name string, in interface{}, opts ...Option) (*xlsx.Sheet, error) {
+	res, err := setupSheet(doc, name, reflect.TypeOf(in), opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "SetupSheet")
 	}
@@ -607,53 +3925,255 @@ func SetupSheet(doc *xlsx.File, name string, in interface{}) (*xlsx.Sheet, error
 	return res, nil
 }
 
-func setupSheet(doc *xlsx.File, name string, t reflect.Type) (*xlsx.Sheet, error) {
+func setupSheet(doc *xlsx.File, name string, t reflect.Type, opts ...Option) (*xlsx.Sheet, error) {
+	o := newOptions(opts)
+
 	names, fields := mapColumnNamesToFieldIndexes(t)
 	if len(names) == 0 {
 		return nil, errors.Errorf("setupSheet: couldn't find column names in struct tags")
 	}
 
-	s, err := Sheet(doc, name)
-	if err != nil {
+	s, err := sheet(doc, name, o.match)
+	if errors.Is(err, ErrSheetNotFound) {
 		ss, err := doc.AddSheet(name)
 		if err != nil {
 			return nil, errors.Wrap(err, "setupSheet: couldn't add sheet")
 		}
 
 		s = ss
+	} else if err != nil {
+		return nil, errors.Wrap(err, "setupSheet")
 	}
 
-	if _, cols := FindHeader(s, 10, names...); len(cols) == len(fields) {
+	// a headerless sheet doesn't need setting up
+	if _, cols, err := indexedColumns(names); err != nil {
+		return nil, errors.Wrap(err, "setupSheet")
+	} else if cols != nil {
 		return s, nil
 	}
 
+	if _, cols := findHeader(s, o.headerLimit, o, names...); len(cols) > 0 && len(missingColumns(fields, cols)) == 0 {
+		return s, nil
+	}
+
+	for _, v := range names {
+		if parent, _ := splitParent(primaryName(v)); parent != "" {
+			writeGroupHeader(s.AddRow(), names, o)
+			break
+		}
+	}
+
 	r := s.AddRow()
 
+	for i := 0; i < o.startColumn; i++ {
+		r.AddCell()
+	}
+
 	for _, v := range names {
-		r.AddCell().SetString(v)
+		_, child := splitParent(primaryName(v))
+
+		c := r.AddCell()
+		c.SetString(child)
+
+		if o.headerStyle != nil {
+			st := *o.headerStyle
+			c.SetStyle(&st)
+		}
 	}
 
 	return s, nil
 }
 
-func SetupSheetAndWriteAll(doc *xlsx.File, name string, in interface{}) error {
+func writeGroupHeader(r *xlsx.Row, names []string, o options) {
+	for i := 0; i < o.startColumn; i++ {
+		r.AddCell()
+	}
+
+	prev := ""
+
+	for _, v := range names {
+		parent, _ := splitParent(primaryName(v))
+
+		c := r.AddCell()
+
+		if parent != prev {
+			c.SetString(parent)
+		}
+
+		if o.headerStyle != nil {
+			st := *o.headerStyle
+			c.SetStyle(&st)
+		}
+
+		prev = parent
+	}
+}
+
+// FreezeHeader freezes the rows of s down to its header, taken to be its first
+// non-blank row. It does nothing to a blank sheet.
+func FreezeHeader(s *xlsx.Sheet) {
+	if i := firstNonBlank(s); i != -1 {
+		FreezeHeaderAt(s, i)
+	}
+}
+
+func FreezeHeaderAt(s *xlsx.Sheet, headerRow int) {
+	s.SheetViews = []xlsx.SheetView{{
+		Pane: &xlsx.Pane{
+			YSplit:      float64(headerRow + 1),
+			TopLeftCell: xlsx.GetCellIDStringFromCoords(0, headerRow+1),
+			ActivePane:  "bottomLeft",
+			State:       "frozen",
+		},
+	}}
+}
+
+func SetupSheetAndWriteAll(doc *xlsx.File, name string, in interface{}, opts ...Option) error {
 	p := reflect.ValueOf(in)
 	if p.Kind() != reflect.Slice {
 		return errors.Errorf("SetupSheetAndWriteAll: expected in to be slice; was instead %s", p.Kind())
 	}
 
 	t := p.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
 	if t.Kind() != reflect.Struct {
-		return errors.Errorf("SetupSheetAndWriteAll: expected in to be slice of struct; was instead slice of %s", t.Kind())
+		return errors.Errorf("SetupSheetAndWriteAll: expected in to be slice of struct or pointer to struct; was instead slice of %s", p.Type().Elem())
 	}
 
-	if _, err := setupSheet(doc, name, t); err != nil {
+	if _, err := setupSheet(doc, name, t, opts...); err != nil {
 		return errors.Wrap(err, "SetupSheetAndWriteAll: couldn't run setupSheet")
 	}
 
-	if err := WriteAll(doc, name, in); err != nil {
+	if err := WriteAll(doc, name, in, opts...); err != nil {
 		return errors.Wrap(err, "SetupSheetAndWriteAll: couldn't run WriteAll")
 	}
 
 	return nil
 }
+
+type RowDiff struct {
+	Key     string
+	Added   bool
+	Removed bool
+	Changes []ColumnChange
+}
+
+type ColumnChange struct {
+	Column string
+	Old    string
+	New    string
+}
+
+// Diff compares two slices of the same struct type, matching up elements by
+// the field tagged with the key option. Values are compared as they would be
+// written to a sheet. Removed and changed rows come first, in the order of
+// before, followed by added rows in the order of after.
+func Diff(before, after interface{}, opts ...Option) ([]RowDiff, error) {
+	a, b := reflect.ValueOf(before), reflect.ValueOf(after)
+	if a.Kind() != reflect.Slice || b.Kind() != reflect.Slice {
+		return nil, errors.Errorf("Diff: expected before and after to be slices; were instead %s and %s", a.Kind(), b.Kind())
+	}
+	if a.Type() != b.Type() {
+		return nil, errors.Errorf("Diff: expected before and after to be the same type; were instead %s and %s", a.Type(), b.Type())
+	}
+
+	t := a.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("Diff: expected slices of struct or pointer to struct; were instead slices of %s", a.Type().Elem())
+	}
+
+	o := newOptions(opts)
+
+	_, fields := mapColumnNamesToFieldIndexes(t)
+
+	key := -1
+	for i, f := range fields {
+		if f.has("key") {
+			key = i
+			break
+		}
+	}
+	if key == -1 {
+		return nil, errors.Errorf("Diff: couldn't find a field tagged with the key option in %s", t)
+	}
+
+	rows := func(p reflect.Value) ([]string, map[string][]string, error) {
+		var keys []string
+		m := make(map[string][]string)
+
+		for i := 0; i < p.Len(); i++ {
+			e := p.Index(i)
+			if e.Kind() == reflect.Ptr {
+				if e.IsNil() {
+					return nil, nil, errors.Errorf("element %d of %d is nil", i, p.Len())
+				}
+
+				e = e.Elem()
+			}
+
+			values := make([]string, len(fields))
+			for j, f := range fields {
+				var tmp xlsx.Cell
+				if err := writeField(&tmp, e.FieldByIndex(f.index), f, o); err != nil {
+					return nil, nil, errors.Wrapf(err, "element %d of %d", i, p.Len())
+				}
+
+				values[j] = tmp.String()
+			}
+
+			k := values[key]
+			if _, ok := m[k]; ok {
+				return nil, nil, errors.Errorf("element %d of %d has duplicate key %q", i, p.Len(), k)
+			}
+
+			keys = append(keys, k)
+			m[k] = values
+		}
+
+		return keys, m, nil
+	}
+
+	oldKeys, oldRows, err := rows(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "Diff: couldn't read before")
+	}
+
+	newKeys, newRows, err := rows(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "Diff: couldn't read after")
+	}
+
+	var res []RowDiff
+
+	for _, k := range oldKeys {
+		n, ok := newRows[k]
+		if !ok {
+			res = append(res, RowDiff{Key: k, Removed: true})
+			continue
+		}
+
+		d := RowDiff{Key: k}
+		for j, v := range oldRows[k] {
+			if v != n[j] {
+				d.Changes = append(d.Changes, ColumnChange{Column: fields[j].name, Old: v, New: n[j]})
+			}
+		}
+
+		if len(d.Changes) > 0 {
+			res = append(res, d)
+		}
+	}
+
+	for _, k := range newKeys {
+		if _, ok := oldRows[k]; !ok {
+			res = append(res, RowDiff{Key: k, Added: true})
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,44 @@
+package xlsxutil
+
+import "testing"
+
+func TestDecimalHeuristic(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"1,234.56", 1234.56},
+		{"1.234,56", 1234.56},
+		{"1.500", 1500},
+		{"1,500", 1500},
+		{"0.500", 0.5},
+		{"0,500", 0.5},
+		{"1,5", 1.5},
+		{"1.5", 1.5},
+		{"1.234.567", 1234567},
+		{"1,234,567", 1234567},
+		{"1.234.567,89", 1234567.89},
+		{"-1.234,5", -1234.5},
+		{"1234", 1234},
+	}
+
+	for _, tt := range tests {
+		var f float64
+		if err := scan(tt.in, &f, newOptions([]Option{WithDecimalHeuristic()})); err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		} else if f != tt.want {
+			t.Errorf("%q: got %v; want %v", tt.in, f, tt.want)
+		}
+	}
+}
+
+func TestDecimalHeuristicIsOptIn(t *testing.T) {
+	var f float64
+	if err := scan("1.500", &f, newOptions(nil)); err != nil || f != 1.5 {
+		t.Errorf("got %v, %v; want 1.5", f, err)
+	}
+
+	if err := scan("1.234,56", &f, newOptions(nil)); err == nil {
+		t.Errorf("got %v; want an error", f)
+	}
+}